@@ -15,12 +15,26 @@ import (
 // through environment variables. Learn more through the documentation of the envconfig package.
 // https://github.com/kelseyhightower/envconfig
 type Specification struct {
-	DiscoveryAttributesExcludesInstance []string      `json:"discoveryAttributesExcludesInstance" split_words:"true" required:"false"`
-	InsecureSkipVerify                  bool          `json:"insecureSkipVerify" split_words:"true" default:"false" required:"false"`
-	EnableRequestLogging                bool          `json:"enableRequestLogging" split_words:"true" default:"false" required:"false"`
-	AdditionalRequestParams             []string      `json:"additionalRequestParams" split_words:"true" required:"false"`
-	QueryRetries                        int           `json:"queryRetries" split_words:"true" default:"0" required:"false"`
-	RequestTimeout                      time.Duration `json:"requestTimeout" split_words:"true" default:"10s" required:"false"`
+	DiscoveryAttributesExcludesInstance     []string      `json:"discoveryAttributesExcludesInstance" split_words:"true" required:"false"`
+	DiscoveryAttributesExcludesRule         []string      `json:"discoveryAttributesExcludesRule" split_words:"true" required:"false"`
+	DiscoveryAttributesExcludesScrapeTarget []string      `json:"discoveryAttributesExcludesScrapeTarget" split_words:"true" required:"false"`
+	InsecureSkipVerify                      bool          `json:"insecureSkipVerify" split_words:"true" default:"false" required:"false"`
+	EnableRequestLogging                    bool          `json:"enableRequestLogging" split_words:"true" default:"false" required:"false"`
+	AdditionalRequestParams                 []string      `json:"additionalRequestParams" split_words:"true" required:"false"`
+	QueryRetries                            int           `json:"queryRetries" split_words:"true" default:"0" required:"false"`
+	RequestTimeout                          time.Duration `json:"requestTimeout" split_words:"true" default:"10s" required:"false"`
+	InstancesConfigFile                     string        `json:"instancesConfigFile" split_words:"true" required:"false"`
+	InstancesConfigReloadInterval           time.Duration `json:"instancesConfigReloadInterval" split_words:"true" default:"10s" required:"false"`
+	MaxIdleConnsPerHost                     int           `json:"maxIdleConnsPerHost" split_words:"true" default:"10" required:"false"`
+	MaxConnsPerHost                         int           `json:"maxConnsPerHost" split_words:"true" default:"0" required:"false"`
+	IdleConnTimeout                         time.Duration `json:"idleConnTimeout" split_words:"true" default:"90s" required:"false"`
+	MaxConcurrentRequests                   int           `json:"maxConcurrentRequests" split_words:"true" default:"0" required:"false"`
+	RequestsPerSecond                       float64       `json:"requestsPerSecond" split_words:"true" default:"0" required:"false"`
+	RequestsBurst                           int           `json:"requestsBurst" split_words:"true" default:"0" required:"false"`
+	CircuitBreakerThreshold                 int           `json:"circuitBreakerThreshold" split_words:"true" default:"0" required:"false"`
+	CircuitBreakerOpenDuration              time.Duration `json:"circuitBreakerOpenDuration" split_words:"true" default:"30s" required:"false"`
+	TracingEnabled                          bool          `json:"tracingEnabled" split_words:"true" default:"false" required:"false"`
+	SkipQueryValidation                     bool          `json:"skipQueryValidation" split_words:"true" default:"false" required:"false"`
 }
 
 var (
@@ -38,6 +52,15 @@ func ParseConfiguration() {
 	if Config.QueryRetries < 0 {
 		log.Fatal().Msgf("QueryRetries must be 0 or a positive integer.")
 	}
+	if Config.InstancesConfigReloadInterval <= 0 {
+		log.Fatal().Msgf("InstancesConfigReloadInterval must be a positive duration.")
+	}
+	if Config.MaxIdleConnsPerHost < 0 || Config.MaxConnsPerHost < 0 || Config.IdleConnTimeout < 0 {
+		log.Fatal().Msgf("MaxIdleConnsPerHost, MaxConnsPerHost and IdleConnTimeout must be 0 or positive.")
+	}
+	if Config.MaxConcurrentRequests < 0 || Config.RequestsPerSecond < 0 || Config.RequestsBurst < 0 || Config.CircuitBreakerThreshold < 0 || Config.CircuitBreakerOpenDuration < 0 {
+		log.Fatal().Msgf("MaxConcurrentRequests, RequestsPerSecond, RequestsBurst, CircuitBreakerThreshold and CircuitBreakerOpenDuration must be 0 or positive.")
+	}
 }
 
 func ValidateConfiguration() {
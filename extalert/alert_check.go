@@ -0,0 +1,294 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extalert
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+	"github.com/steadybit/action-kit/go/action_kit_sdk"
+	extension_kit "github.com/steadybit/extension-kit"
+	"github.com/steadybit/extension-kit/extbuild"
+	"github.com/steadybit/extension-kit/extutil"
+	"github.com/steadybit/extension-prometheus/v2/extcheck"
+	"github.com/steadybit/extension-prometheus/v2/extinstance"
+)
+
+const (
+	alertStatePendingOrFiring = "pendingOrFiring"
+	alertStateFiring          = "firing"
+
+	expectationReached    = "reached"
+	expectationNotReached = "notReached"
+)
+
+type AlertCheckAction struct {
+}
+
+type AlertCheckState struct {
+	TargetName  string            `json:"targetName"`
+	AlertName   string            `json:"alertName"`
+	Labels      map[string]string `json:"labels"`
+	AlertState  string            `json:"alertState"`
+	Expectation string            `json:"expectation"`
+	// IgnoreActiveAlerts only lets alerts which became active after the start reach the expected state.
+	IgnoreActiveAlerts bool      `json:"ignoreActiveAlerts"`
+	Duration           int64     `json:"duration"`
+	Start              time.Time `json:"start"`
+	End                time.Time `json:"end"`
+}
+
+func NewAlertCheckAction() action_kit_sdk.Action[AlertCheckState] {
+	return AlertCheckAction{}
+}
+
+// Make sure AlertCheckAction implements all required interfaces
+var _ action_kit_sdk.Action[AlertCheckState] = (*AlertCheckAction)(nil)
+var _ action_kit_sdk.ActionWithStatus[AlertCheckState] = (*AlertCheckAction)(nil)
+
+func (f AlertCheckAction) NewEmptyState() AlertCheckState {
+	return AlertCheckState{}
+}
+
+func (f AlertCheckAction) Describe() action_kit_api.ActionDescription {
+	return action_kit_api.ActionDescription{
+		Id:          fmt.Sprintf("%s.alert-state", extinstance.PrometheusInstanceTargetId),
+		Label:       "Prometheus alert state",
+		Description: "Check whether a Prometheus alert reaches the pending or firing state",
+		Version:     extbuild.GetSemverVersionStringOrUnknown(),
+		Icon:        extutil.Ptr(extinstance.PrometheusIcon),
+		Technology:  new("Prometheus"),
+
+		TargetSelection: new(action_kit_api.TargetSelection{
+			TargetType:          extinstance.PrometheusInstanceTargetId,
+			QuantityRestriction: extutil.Ptr(action_kit_api.QuantityRestrictionExactlyOne),
+			SelectionTemplates: new([]action_kit_api.TargetSelectionTemplate{
+				{
+					Label:       "instance-name",
+					Description: new("Find prometheus-instance by instance-name"),
+					Query:       "prometheus.instance.name=\"\"",
+				},
+			}),
+		}),
+		Kind:        action_kit_api.Check,
+		TimeControl: action_kit_api.TimeControlInternal,
+		Parameters: []action_kit_api.ActionParameter{
+			{
+				Label:        "Duration",
+				Name:         "duration",
+				Description:  new("How long to wait for the alert."),
+				Type:         action_kit_api.ActionParameterTypeDuration,
+				Required:     new(true),
+				DefaultValue: new("5m"),
+				Order:        new(0),
+			},
+			{
+				Label:       "Alert Name",
+				Name:        "alertName",
+				Description: new("Name of the alerting rule, i.e. the value of the alertname label."),
+				Type:        action_kit_api.ActionParameterTypeString,
+				Required:    new(true),
+				Options: new([]action_kit_api.ParameterOption{
+					action_kit_api.ParameterOptionsFromTargetAttribute{Attribute: "prometheus.rule.name"},
+				}),
+				Order: new(1),
+			},
+			{
+				Label:       "Alert Labels",
+				Name:        "alertLabels",
+				Description: new("Only consider alerts having all of these labels, e.g. namespace=shop."),
+				Type:        action_kit_api.ActionParameterTypeKeyValue,
+				Required:    new(false),
+				Order:       new(2),
+			},
+			{
+				Label:        "Alert State",
+				Name:         "alertState",
+				Description:  new("Which state counts as an active alert?"),
+				Type:         action_kit_api.ActionParameterTypeString,
+				Required:     new(true),
+				DefaultValue: new(alertStateFiring),
+				Options: new([]action_kit_api.ParameterOption{
+					action_kit_api.ExplicitParameterOption{Label: "Firing", Value: alertStateFiring},
+					action_kit_api.ExplicitParameterOption{Label: "Pending or firing", Value: alertStatePendingOrFiring},
+				}),
+				Order: new(3),
+			},
+			{
+				Label:        "Expectation",
+				Name:         "expectation",
+				Description:  new("Should the alert reach the state during the check?"),
+				Type:         action_kit_api.ActionParameterTypeString,
+				Required:     new(true),
+				DefaultValue: new(expectationReached),
+				Options: new([]action_kit_api.ParameterOption{
+					action_kit_api.ExplicitParameterOption{Label: "Alert reaches the state", Value: expectationReached},
+					action_kit_api.ExplicitParameterOption{Label: "Alert never reaches the state", Value: expectationNotReached},
+				}),
+				Order: new(4),
+			},
+			{
+				Label:        "Ignore Already Active Alerts",
+				Name:         "ignoreActiveAlerts",
+				Description:  new("Alerts which were already active before the check started don't count as reaching the state."),
+				Type:         action_kit_api.ActionParameterTypeBoolean,
+				Required:     new(false),
+				Advanced:     new(true),
+				DefaultValue: new("true"),
+				Order:        new(5),
+			},
+		},
+		Prepare: action_kit_api.MutatingEndpointReference{},
+		Start:   action_kit_api.MutatingEndpointReference{},
+		Status: new(action_kit_api.MutatingEndpointReferenceWithCallInterval{
+			CallInterval: new("2s"),
+		}),
+	}
+}
+
+func (f AlertCheckAction) Prepare(_ context.Context, state *AlertCheckState, request action_kit_api.PrepareActionRequestBody) (*action_kit_api.PrepareResult, error) {
+	if request.Target == nil {
+		return nil, new(extension_kit.ToError("No Prometheus instance selected", nil))
+	}
+	if _, err := extinstance.FindInstanceByName(request.Target.Name); err != nil {
+		return nil, new(extension_kit.ToError(fmt.Sprintf("Failed to find Prometheus instance named '%s'", request.Target.Name), err))
+	}
+
+	state.TargetName = request.Target.Name
+	state.Duration = extutil.ToInt64(request.Config["duration"])
+	state.AlertName = strings.TrimSpace(extutil.ToString(request.Config["alertName"]))
+	if state.AlertName == "" {
+		return nil, new(extension_kit.ToError("No alert name defined", nil))
+	}
+
+	if request.Config["alertLabels"] != nil {
+		labels, err := extutil.ToKeyValue(request.Config, "alertLabels")
+		if err != nil {
+			return nil, new(extension_kit.ToError("Invalid alert labels", err))
+		}
+		state.Labels = labels
+	}
+
+	state.AlertState = extutil.ToString(request.Config["alertState"])
+	if state.AlertState != alertStateFiring && state.AlertState != alertStatePendingOrFiring {
+		return nil, new(extension_kit.ToError(fmt.Sprintf("Unsupported alert state '%s'", state.AlertState), nil))
+	}
+	state.Expectation = extutil.ToString(request.Config["expectation"])
+	if state.Expectation != expectationReached && state.Expectation != expectationNotReached {
+		return nil, new(extension_kit.ToError(fmt.Sprintf("Unsupported expectation '%s'", state.Expectation), nil))
+	}
+	state.IgnoreActiveAlerts = extutil.ToBool(request.Config["ignoreActiveAlerts"])
+	return nil, nil
+}
+
+func (f AlertCheckAction) Start(_ context.Context, state *AlertCheckState) (*action_kit_api.StartResult, error) {
+	state.Start = time.Now()
+	state.End = state.Start.Add(time.Duration(state.Duration) * time.Millisecond)
+	return nil, nil
+}
+
+func (f AlertCheckAction) Status(ctx context.Context, state *AlertCheckState) (*action_kit_api.StatusResult, error) {
+	now := time.Now()
+	completed := !now.Before(state.End)
+
+	instance, err := extinstance.FindInstanceByName(state.TargetName)
+	if err != nil {
+		return nil, new(extension_kit.ToError(fmt.Sprintf("Failed to find Prometheus instance named '%s'", state.TargetName), err))
+	}
+
+	client, err := instance.GetApiClient()
+	if err != nil {
+		return nil, new(extension_kit.ToError("Failed to initialize Prometheus API client", err))
+	}
+
+	alerts, err := fetchAlerts(ctx, instance, client)
+	if err != nil {
+		return nil, new(extension_kit.ToError(fmt.Sprintf("Failed to fetch alerts from instance '%s'", state.TargetName), err))
+	}
+
+	// An alert which already fired before the check started wasn't caused by the experiment. It still violates the
+	// expectation to never reach the state, though.
+	var activeSince time.Time
+	if state.Expectation == expectationReached && state.IgnoreActiveAlerts {
+		activeSince = state.Start
+	}
+	alert := findMatchingAlert(alerts, state, activeSince)
+	if alert != nil {
+		description := fmt.Sprintf("Alert '%s' is %s since %s (%s) with labels %s.",
+			state.AlertName, alert.State, alert.ActiveAt.Format(time.RFC3339), describeActiveAt(alert.ActiveAt, state.Start), alert.Labels)
+		if state.Expectation == expectationNotReached {
+			return extcheck.Failed("Alert check failed", description), nil
+		}
+		return &action_kit_api.StatusResult{
+			Completed: true,
+			Messages: new([]action_kit_api.Message{
+				{
+					Level:   new(action_kit_api.Info),
+					Message: description,
+				},
+			}),
+		}, nil
+	}
+
+	if completed && state.Expectation == expectationReached {
+		return extcheck.Failed("Alert check failed", fmt.Sprintf("Alert '%s' did not reach the %s state within %s.",
+			state.AlertName, describeAlertState(state.AlertState), state.End.Sub(state.Start).Round(time.Second))), nil
+	}
+
+	return &action_kit_api.StatusResult{Completed: completed}, nil
+}
+
+func fetchAlerts(ctx context.Context, instance *extinstance.Instance, client v1.API) ([]v1.Alert, error) {
+	var alerts []v1.Alert
+	err := instance.Retry(ctx, func(ctx context.Context) error {
+		result, err := client.Alerts(ctx)
+		if err != nil {
+			return err
+		}
+		alerts = result.Alerts
+		return nil
+	})
+	return alerts, err
+}
+
+// findMatchingAlert returns the first alert with the configured name and labels which is in one of the expected states
+// and became active at or after activeSince.
+func findMatchingAlert(alerts []v1.Alert, state *AlertCheckState, activeSince time.Time) *v1.Alert {
+	for i, alert := range alerts {
+		if string(alert.Labels[model.AlertNameLabel]) != state.AlertName {
+			continue
+		}
+		if alert.State != v1.AlertStateFiring && (state.AlertState != alertStatePendingOrFiring || alert.State != v1.AlertStatePending) {
+			continue
+		}
+		if !extcheck.HasLabels(alert.Labels, state.Labels) {
+			continue
+		}
+		if alert.ActiveAt.Before(activeSince) {
+			continue
+		}
+		return &alerts[i]
+	}
+	return nil
+}
+
+// describeActiveAt describes when an alert became active relative to the start of the check.
+func describeActiveAt(activeAt time.Time, start time.Time) string {
+	if activeAt.Before(start) {
+		return fmt.Sprintf("%s before the check started", start.Sub(activeAt).Round(time.Second))
+	}
+	return fmt.Sprintf("%s after the check started", activeAt.Sub(start).Round(time.Second))
+}
+
+func describeAlertState(alertState string) string {
+	if alertState == alertStatePendingOrFiring {
+		return "pending or firing"
+	}
+	return "firing"
+}
@@ -0,0 +1,185 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extalert
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+	"github.com/steadybit/action-kit/go/action_kit_sdk"
+	"github.com/steadybit/extension-prometheus/v2/extinstance"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAlerts = `[
+  {"labels":{"alertname":"HighErrorRate","namespace":"shop","severity":"critical"},"annotations":{},"state":"pending","activeAt":"2026-01-01T10:00:00Z","value":"1"},
+  {"labels":{"alertname":"HighErrorRate","namespace":"checkout","severity":"critical"},"annotations":{},"state":"firing","activeAt":"2026-01-01T10:00:00Z","value":"1"},
+  {"labels":{"alertname":"Watchdog"},"annotations":{},"state":"firing","activeAt":"2026-01-01T09:00:00Z","value":"1"}
+]`
+
+func TestPrepare(t *testing.T) {
+	extinstance.SetInstances([]extinstance.Instance{{Name: "test-prom", BaseUrl: "http://localhost:9090"}})
+	action := NewAlertCheckAction()
+
+	t.Run("valid", func(t *testing.T) {
+		state := action.NewEmptyState()
+		_, err := action.Prepare(context.Background(), &state, prepareRequest(map[string]any{
+			"duration":           float64(60000),
+			"alertName":          "HighErrorRate",
+			"alertLabels":        []any{map[string]any{"key": "namespace", "value": "shop"}},
+			"alertState":         alertStatePendingOrFiring,
+			"expectation":        expectationReached,
+			"ignoreActiveAlerts": true,
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, "HighErrorRate", state.AlertName)
+		assert.Equal(t, map[string]string{"namespace": "shop"}, state.Labels)
+		assert.Equal(t, int64(60000), state.Duration)
+		assert.True(t, state.IgnoreActiveAlerts)
+	})
+
+	t.Run("missing alert name", func(t *testing.T) {
+		state := action.NewEmptyState()
+		_, err := action.Prepare(context.Background(), &state, prepareRequest(map[string]any{
+			"alertState":  alertStateFiring,
+			"expectation": expectationReached,
+		}))
+		assert.ErrorContains(t, err, "No alert name defined")
+	})
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		alertState  string
+		expectation string
+		ended       bool
+		wantDone    bool
+		wantFailed  bool
+	}{
+		{name: "firing alert reached", labels: map[string]string{"namespace": "checkout"}, alertState: alertStateFiring, expectation: expectationReached, wantDone: true},
+		{name: "pending alert not yet firing", labels: map[string]string{"namespace": "shop"}, alertState: alertStateFiring, expectation: expectationReached},
+		{name: "pending alert counts as reached", labels: map[string]string{"namespace": "shop"}, alertState: alertStatePendingOrFiring, expectation: expectationReached, wantDone: true},
+		{name: "alert not reached in time", labels: map[string]string{"namespace": "shop"}, alertState: alertStateFiring, expectation: expectationReached, ended: true, wantDone: true, wantFailed: true},
+		{name: "alert fired unexpectedly", labels: map[string]string{"namespace": "checkout"}, alertState: alertStateFiring, expectation: expectationNotReached, wantDone: true, wantFailed: true},
+		{name: "alert stayed silent", labels: map[string]string{"namespace": "payment"}, alertState: alertStatePendingOrFiring, expectation: expectationNotReached, ended: true, wantDone: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := setupAlertsInstance(t, testAlerts)
+			extinstance.SetInstances([]extinstance.Instance{{Name: "test-prom", BaseUrl: url}})
+			action := NewAlertCheckAction().(action_kit_sdk.ActionWithStatus[AlertCheckState])
+
+			state := AlertCheckState{
+				TargetName:  "test-prom",
+				AlertName:   "HighErrorRate",
+				Labels:      tt.labels,
+				AlertState:  tt.alertState,
+				Expectation: tt.expectation,
+				Start:       time.Now().Add(-time.Minute),
+				End:         time.Now().Add(time.Minute),
+			}
+			if tt.ended {
+				state.End = time.Now().Add(-time.Second)
+			}
+
+			result, err := action.Status(context.Background(), &state)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantDone, result.Completed)
+			if tt.wantFailed {
+				require.NotNil(t, result.Error)
+				assert.Equal(t, action_kit_api.Failed, *result.Error.Status)
+				return
+			}
+			assert.Nil(t, result.Error)
+		})
+	}
+}
+
+func TestStatus_IgnoresAlertsActiveBeforeStart(t *testing.T) {
+	url := setupAlertsInstance(t, testAlerts)
+	extinstance.SetInstances([]extinstance.Instance{{Name: "test-prom", BaseUrl: url}})
+	action := NewAlertCheckAction().(action_kit_sdk.ActionWithStatus[AlertCheckState])
+	activeAt := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	newState := func(expectation string, start time.Time) AlertCheckState {
+		return AlertCheckState{
+			TargetName:         "test-prom",
+			AlertName:          "HighErrorRate",
+			Labels:             map[string]string{"namespace": "checkout"},
+			AlertState:         alertStateFiring,
+			Expectation:        expectation,
+			IgnoreActiveAlerts: true,
+			Start:              start,
+			End:                time.Now().Add(time.Minute),
+		}
+	}
+
+	t.Run("alert fired after the start", func(t *testing.T) {
+		state := newState(expectationReached, activeAt.Add(-90*time.Second))
+		result, err := action.Status(context.Background(), &state)
+		require.NoError(t, err)
+		assert.True(t, result.Completed)
+		assert.Nil(t, result.Error)
+		assert.Contains(t, (*result.Messages)[0].Message, "is firing since 2026-01-01T10:00:00Z (1m30s after the check started)")
+	})
+
+	t.Run("alert already fired before the start", func(t *testing.T) {
+		state := newState(expectationReached, activeAt.Add(time.Minute))
+		result, err := action.Status(context.Background(), &state)
+		require.NoError(t, err)
+		assert.False(t, result.Completed)
+	})
+
+	t.Run("alert already fired before the start counts if not ignored", func(t *testing.T) {
+		state := newState(expectationReached, activeAt.Add(time.Minute))
+		state.IgnoreActiveAlerts = false
+		result, err := action.Status(context.Background(), &state)
+		require.NoError(t, err)
+		assert.True(t, result.Completed)
+		assert.Contains(t, (*result.Messages)[0].Message, "(1m0s before the check started)")
+	})
+
+	t.Run("alert already fired before the start violates never reaching the state", func(t *testing.T) {
+		state := newState(expectationNotReached, activeAt.Add(time.Minute))
+		result, err := action.Status(context.Background(), &state)
+		require.NoError(t, err)
+		require.NotNil(t, result.Error)
+		assert.Equal(t, action_kit_api.Failed, *result.Error.Status)
+	})
+}
+
+func prepareRequest(config map[string]any) action_kit_api.PrepareActionRequestBody {
+	return action_kit_api.PrepareActionRequestBody{
+		Target: new(action_kit_api.Target{
+			Name: "test-prom",
+		}),
+		Config: config,
+	}
+}
+
+func setupAlertsInstance(t *testing.T, alerts string) (url string) {
+	t.Helper()
+
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/v1/alerts", r.URL.Path)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			if _, err := fmt.Fprintf(w, `{"status":"success","data":{"alerts":%s}}`, alerts); err != nil {
+				http.Error(w, "Failed to write response", http.StatusInternalServerError)
+			}
+		}),
+	)
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+// Package extcheck contains the behavior shared by the checks, e.g., how the check mode decides their verdict.
+package extcheck
+
+import (
+	"fmt"
+
+	"github.com/prometheus/common/model"
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+)
+
+const (
+	ModeAllTheTime  = "allTheTime"
+	ModeAtLeastOnce = "atLeastOnce"
+	ModeAtEnd       = "atEnd"
+)
+
+// ModeParameter describes the parameter for the check mode. The description asks when the condition of the check must
+// be met.
+func ModeParameter(description string, order int) action_kit_api.ActionParameter {
+	return action_kit_api.ActionParameter{
+		Label:        "Check Mode",
+		Name:         "checkMode",
+		Description:  new(description),
+		Type:         action_kit_api.ActionParameterTypeString,
+		Required:     new(false),
+		DefaultValue: new(ModeAllTheTime),
+		Options: new([]action_kit_api.ParameterOption{
+			action_kit_api.ExplicitParameterOption{Label: "All the time", Value: ModeAllTheTime},
+			action_kit_api.ExplicitParameterOption{Label: "At least once", Value: ModeAtLeastOnce},
+			action_kit_api.ExplicitParameterOption{Label: "At the end", Value: ModeAtEnd},
+		}),
+		Order: new(order),
+	}
+}
+
+// ParseMode validates the configured check mode. Without one, the condition must be met all the time.
+func ParseMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return ModeAllTheTime, nil
+	case ModeAllTheTime, ModeAtLeastOnce, ModeAtEnd:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported check mode '%s'", mode)
+	}
+}
+
+// SkipEvaluation tells whether a status call doesn't need to evaluate the condition, as only the final evaluation
+// decides the verdict in ModeAtEnd.
+func SkipEvaluation(mode string, completed bool) bool {
+	return mode == ModeAtEnd && !completed
+}
+
+// Verdict decides the result of a status call according to the check mode. violation describes why the current
+// evaluation didn't meet the condition and is empty otherwise, metOnce tells whether any evaluation so far met it.
+// notMetOnce is the failure of ModeAtLeastOnce if no evaluation met the condition until the check completed.
+func Verdict(title string, mode string, completed bool, violation string, metOnce bool, notMetOnce string) *action_kit_api.StatusResult {
+	switch mode {
+	case ModeAtLeastOnce:
+		if completed && !metOnce {
+			return Failed(title, notMetOnce)
+		}
+	default:
+		if violation != "" {
+			return Failed(title, violation)
+		}
+	}
+	return &action_kit_api.StatusResult{Completed: completed}
+}
+
+// Failed completes a check whose expectation isn't met. The detail is reported as message as well, so it shows up in
+// the log of the step.
+func Failed(title string, detail string) *action_kit_api.StatusResult {
+	return &action_kit_api.StatusResult{
+		Completed: true,
+		Error: &action_kit_api.ActionKitError{
+			Title:  title,
+			Detail: new(detail),
+			Status: new(action_kit_api.Failed),
+		},
+		Messages: new([]action_kit_api.Message{
+			{
+				Level:   new(action_kit_api.Error),
+				Message: detail,
+			},
+		}),
+	}
+}
+
+// HasLabels tells whether the labels contain all the expected ones.
+func HasLabels(labels model.LabelSet, expected map[string]string) bool {
+	for key, value := range expected {
+		if string(labels[model.LabelName(key)]) != value {
+			return false
+		}
+	}
+	return true
+}
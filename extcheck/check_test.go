@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extcheck
+
+import (
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMode(t *testing.T) {
+	mode, err := ParseMode("")
+	require.NoError(t, err)
+	assert.Equal(t, ModeAllTheTime, mode)
+
+	mode, err = ParseMode(ModeAtEnd)
+	require.NoError(t, err)
+	assert.Equal(t, ModeAtEnd, mode)
+
+	_, err = ParseMode("sometimes")
+	assert.EqualError(t, err, "unsupported check mode 'sometimes'")
+}
+
+func TestSkipEvaluation(t *testing.T) {
+	assert.True(t, SkipEvaluation(ModeAtEnd, false))
+	assert.False(t, SkipEvaluation(ModeAtEnd, true))
+	assert.False(t, SkipEvaluation(ModeAllTheTime, false))
+	assert.False(t, SkipEvaluation(ModeAtLeastOnce, false))
+}
+
+func TestVerdict(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          string
+		completed     bool
+		violation     string
+		metOnce       bool
+		wantCompleted bool
+		wantFailure   string
+	}{
+		{name: "all the time met", mode: ModeAllTheTime, wantCompleted: false},
+		{name: "all the time violated", mode: ModeAllTheTime, violation: "too high", wantCompleted: true, wantFailure: "too high"},
+		{name: "at end met", mode: ModeAtEnd, completed: true, wantCompleted: true},
+		{name: "at end violated", mode: ModeAtEnd, completed: true, violation: "too high", wantCompleted: true, wantFailure: "too high"},
+		{name: "at least once violated before the end", mode: ModeAtLeastOnce, violation: "too high", wantCompleted: false},
+		{name: "at least once met before", mode: ModeAtLeastOnce, completed: true, violation: "too high", metOnce: true, wantCompleted: true},
+		{name: "at least once never met", mode: ModeAtLeastOnce, completed: true, violation: "too high", wantCompleted: true, wantFailure: "never met"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Verdict("Test check failed", tt.mode, tt.completed, tt.violation, tt.metOnce, "never met")
+			assert.Equal(t, tt.wantCompleted, result.Completed)
+			if tt.wantFailure == "" {
+				assert.Nil(t, result.Error)
+				return
+			}
+			require.NotNil(t, result.Error)
+			assert.Equal(t, "Test check failed", result.Error.Title)
+			assert.Equal(t, tt.wantFailure, *result.Error.Detail)
+			assert.Equal(t, action_kit_api.Failed, *result.Error.Status)
+			assert.Equal(t, tt.wantFailure, (*result.Messages)[0].Message)
+		})
+	}
+}
+
+func TestHasLabels(t *testing.T) {
+	labels := model.LabelSet{"job": "checkout", "namespace": "shop"}
+	assert.True(t, HasLabels(labels, nil))
+	assert.True(t, HasLabels(labels, map[string]string{"namespace": "shop"}))
+	assert.False(t, HasLabels(labels, map[string]string{"namespace": "staging"}))
+	assert.False(t, HasLabels(labels, map[string]string{"pod": "checkout-1"}))
+}
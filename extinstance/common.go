@@ -5,5 +5,7 @@ package extinstance
 
 const (
 	PrometheusInstanceTargetId = "com.steadybit.extension_prometheus.instance"
+	PrometheusRuleTargetId     = "com.steadybit.extension_prometheus.rule"
+	PrometheusScrapeTargetId   = "com.steadybit.extension_prometheus.scrape-target"
 	PrometheusIcon             = "data:image/svg+xml,%3Csvg%20width%3D%2224%22%20height%3D%2225%22%20viewBox%3D%220%200%2024%2025%22%20fill%3D%22none%22%20xmlns%3D%22http%3A%2F%2Fwww.w3.org%2F2000%2Fsvg%22%3E%3Cpath%20d%3D%22M12%202.5c-5.522%200-10%204.477-10%2010s4.478%2010%2010%2010c5.523%200%2010-4.477%2010-10s-4.477-10-10-10zm0%2018.716c-1.571%200-2.845-1.05-2.845-2.344h5.69c0%201.294-1.273%202.344-2.845%202.344zm4.7-3.12H7.3V16.39h9.4v1.705zm-.034-2.582H7.327c-.031-.036-.063-.071-.093-.108-.962-1.168-1.189-1.778-1.409-2.4-.003-.02%201.167.24%201.997.427%200%200%20.427.098%201.051.212-.599-.702-.955-1.596-.955-2.509%200-2.004%201.538-3.756.983-5.172.54.044%201.117%201.14%201.156%202.852.574-.793.814-2.241.814-3.13%200-.919.606-1.987%201.212-2.023-.54.89.14%201.653.745%203.547.226.71.197%201.908.373%202.667C13.258%208.3%2013.53%206%2014.53%205.206c-.441%201%20.065%202.251.411%202.853.56.97.898%201.706.898%203.097%200%20.932-.344%201.81-.925%202.496.66-.123%201.116-.235%201.116-.235l2.145-.418s-.312%201.28-1.509%202.515z%22%20fill%3D%22currentColor%22%2F%3E%3C%2Fsvg%3E"
 )
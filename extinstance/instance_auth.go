@@ -0,0 +1,301 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extinstance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/steadybit/extension-prometheus/v2/config"
+)
+
+const (
+	authTypeBasic      = "basic"
+	authTypeBearerFile = "bearerFile"
+	authTypeOAuth2     = "oauth2"
+
+	// oauth2ExpiryDelta refreshes OAuth2 tokens a bit before they actually expire to account for clock skew and
+	// request latency.
+	oauth2ExpiryDelta = 30 * time.Second
+	// oauth2DefaultLifetime bounds the caching of OAuth2 tokens whose response doesn't tell when they expire.
+	oauth2DefaultLifetime = 5 * time.Minute
+)
+
+// AuthConfig configures how the extension authenticates against a Prometheus instance.
+type AuthConfig struct {
+	// Type is one of basic, bearerFile or oauth2.
+	Type string `json:"type"`
+
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// TokenFile is re-read whenever it changes, e.g., for rotated Kubernetes service account tokens.
+	TokenFile string `json:"tokenFile,omitempty"`
+
+	// ClientId, ClientSecret, TokenUrl and Scopes configure the OAuth2 client credentials flow.
+	ClientId     string   `json:"clientId,omitempty"`
+	ClientSecret string   `json:"clientSecret,omitempty"`
+	TokenUrl     string   `json:"tokenUrl,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+	// TokenCaFile is a PEM bundle of CAs used to verify the token endpoint instead of the system roots.
+	TokenCaFile string `json:"tokenCaFile,omitempty"`
+}
+
+func (a *AuthConfig) validate() error {
+	switch a.Type {
+	case authTypeBasic:
+		if a.Username == "" {
+			return fmt.Errorf("basic auth requires a username")
+		}
+	case authTypeBearerFile:
+		if a.TokenFile == "" {
+			return fmt.Errorf("bearer file auth requires a tokenFile")
+		}
+	case authTypeOAuth2:
+		if a.ClientId == "" || a.ClientSecret == "" || a.TokenUrl == "" {
+			return fmt.Errorf("oauth2 auth requires a clientId, clientSecret and tokenUrl")
+		}
+	default:
+		return fmt.Errorf("unsupported auth type '%s'", a.Type)
+	}
+	return nil
+}
+
+// authenticator adds credentials to requests sent to a Prometheus instance.
+type authenticator interface {
+	authenticate(req *http.Request) error
+}
+
+// tokenRejecter is implemented by authenticators caching tokens, so a token rejected by the instance isn't used again,
+// e.g., after it was revoked.
+type tokenRejecter interface {
+	reject(req *http.Request)
+}
+
+// newAuthenticator creates the authenticator for the given config. The client is used to request OAuth2 tokens.
+func newAuthenticator(auth *AuthConfig, client *http.Client) (authenticator, error) {
+	if err := auth.validate(); err != nil {
+		return nil, err
+	}
+	switch auth.Type {
+	case authTypeBasic:
+		return &basicAuthenticator{username: auth.Username, password: auth.Password}, nil
+	case authTypeBearerFile:
+		return &bearerFileAuthenticator{path: auth.TokenFile}, nil
+	default:
+		return &oauth2Authenticator{source: getOAuth2TokenSource(auth), client: client}, nil
+	}
+}
+
+// newOAuth2Transport creates the transport to the token endpoint. The endpoint usually is an identity provider on
+// another host, so it is verified against the system roots or the TokenCaFile instead of the TLS config of the instance.
+func newOAuth2Transport(auth *AuthConfig) (*http.Transport, error) {
+	tokenUrl, err := url.Parse(auth.TokenUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tokenUrl: %w", err)
+	}
+	var tlsConfig *TLSConfig
+	if auth.TokenCaFile != "" {
+		tlsConfig = &TLSConfig{CaFile: auth.TokenCaFile}
+	}
+	tlsClientConfig, err := newTLSClientConfig(tlsConfig, tokenUrl.Hostname(), config.Config.InsecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsClientConfig
+	return transport, nil
+}
+
+type basicAuthenticator struct {
+	username string
+	password string
+}
+
+func (b *basicAuthenticator) authenticate(req *http.Request) error {
+	req.SetBasicAuth(b.username, b.password)
+	return nil
+}
+
+// bearerFileAuthenticator sends the content of a file as bearer token. The file is read again once its modification
+// time changes, so rotated tokens are picked up without a restart.
+type bearerFileAuthenticator struct {
+	path    string
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+}
+
+func (b *bearerFileAuthenticator) authenticate(req *http.Request) error {
+	token, err := b.currentToken()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (b *bearerFileAuthenticator) currentToken() (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read bearer token file: %w", err)
+	}
+	if b.token != "" && info.ModTime().Equal(b.modTime) {
+		return b.token, nil
+	}
+
+	content, err := os.ReadFile(b.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read bearer token file: %w", err)
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("bearer token file '%s' is empty", b.path)
+	}
+	b.token = token
+	b.modTime = info.ModTime()
+	return b.token, nil
+}
+
+type oauth2Authenticator struct {
+	source *oauth2TokenSource
+	client *http.Client
+}
+
+func (o *oauth2Authenticator) authenticate(req *http.Request) error {
+	token, err := o.source.token(req, o.client)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (o *oauth2Authenticator) reject(req *http.Request) {
+	o.source.invalidate(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "))
+}
+
+// oauth2TokenSource fetches tokens using the OAuth2 client credentials flow and caches them until they expire, or for
+// oauth2DefaultLifetime if the token response has no expires_in.
+type oauth2TokenSource struct {
+	clientId     string
+	clientSecret string
+	tokenUrl     string
+	scopes       []string
+
+	mu          sync.Mutex
+	accessToken string
+	expiry      time.Time
+}
+
+// oauth2TokenSources are shared across API clients, so the clients of all tenants of an instance use the same token,
+// and tokens survive clients being replaced, e.g., after a reload of the instances config file or an idle eviction.
+var oauth2TokenSources sync.Map
+
+func getOAuth2TokenSource(auth *AuthConfig) *oauth2TokenSource {
+	key := strings.Join(append([]string{auth.TokenUrl, auth.ClientId, auth.ClientSecret}, auth.Scopes...), "\x00")
+	source, _ := oauth2TokenSources.LoadOrStore(key, &oauth2TokenSource{
+		clientId:     auth.ClientId,
+		clientSecret: auth.ClientSecret,
+		tokenUrl:     auth.TokenUrl,
+		scopes:       auth.Scopes,
+	})
+	return source.(*oauth2TokenSource)
+}
+
+type oauth2TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func (s *oauth2TokenSource) token(req *http.Request, client *http.Client) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accessToken != "" && time.Now().Before(s.expiry.Add(-oauth2ExpiryDelta)) {
+		return s.accessToken, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.scopes) > 0 {
+		form.Set("scope", strings.Join(s.scopes, " "))
+	}
+	tokenReq, err := http.NewRequestWithContext(req.Context(), http.MethodPost, s.tokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	tokenReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	tokenReq.SetBasicAuth(url.QueryEscape(s.clientId), url.QueryEscape(s.clientSecret))
+
+	resp, err := client.Do(tokenReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to request oauth2 token: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read oauth2 token response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("failed to request oauth2 token: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var token oauth2TokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("failed to parse oauth2 token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("oauth2 token response contains no access_token")
+	}
+
+	lifetime := oauth2DefaultLifetime
+	if token.ExpiresIn > 0 {
+		lifetime = time.Duration(token.ExpiresIn) * time.Second
+	}
+	s.accessToken = token.AccessToken
+	s.expiry = time.Now().Add(lifetime)
+	return s.accessToken, nil
+}
+
+// invalidate drops the cached token if it's still the given one, so the next request fetches a new token. A token
+// fetched by a concurrent request in the meantime is kept.
+func (s *oauth2TokenSource) invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.accessToken == token {
+		s.accessToken = ""
+		s.expiry = time.Time{}
+	}
+}
+
+// authRoundTripper adds the credentials of an authenticator to each request
+type authRoundTripper struct {
+	auth authenticator
+	rt   http.RoundTripper
+}
+
+func (a *authRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if err := a.auth.authenticate(req); err != nil {
+		return nil, err
+	}
+	resp, err := a.rt.RoundTrip(req)
+	if rejecter, ok := a.auth.(tokenRejecter); ok && err == nil && resp.StatusCode == http.StatusUnauthorized {
+		rejecter.reject(req)
+	}
+	return resp, err
+}
@@ -0,0 +1,242 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extinstance
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		auth      AuthConfig
+		wantError string
+	}{
+		{name: "basic", auth: AuthConfig{Type: authTypeBasic, Username: "user", Password: "secret"}},
+		{name: "basic without username", auth: AuthConfig{Type: authTypeBasic}, wantError: "requires a username"},
+		{name: "bearer file", auth: AuthConfig{Type: authTypeBearerFile, TokenFile: "/var/run/token"}},
+		{name: "bearer file without file", auth: AuthConfig{Type: authTypeBearerFile}, wantError: "requires a tokenFile"},
+		{name: "oauth2", auth: AuthConfig{Type: authTypeOAuth2, ClientId: "id", ClientSecret: "secret", TokenUrl: "http://idp/token"}},
+		{name: "oauth2 without token url", auth: AuthConfig{Type: authTypeOAuth2, ClientId: "id", ClientSecret: "secret"}, wantError: "requires a clientId, clientSecret and tokenUrl"},
+		{name: "unknown type", auth: AuthConfig{Type: "kerberos"}, wantError: "unsupported auth type 'kerberos'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.auth.validate()
+			if tt.wantError != "" {
+				assert.ErrorContains(t, err, tt.wantError)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestBasicAuthenticator(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://prometheus/api/v1/query", nil)
+	require.NoError(t, (&basicAuthenticator{username: "user", password: "secret"}).authenticate(req))
+
+	username, password, ok := req.BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "secret", password)
+}
+
+func TestBearerFileAuthenticator_PicksUpRotatedToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(path, []byte("first-token\n"), 0o600))
+	auth := &bearerFileAuthenticator{path: path}
+
+	req := httptest.NewRequest(http.MethodGet, "http://prometheus/api/v1/query", nil)
+	require.NoError(t, auth.authenticate(req))
+	assert.Equal(t, "Bearer first-token", req.Header.Get("Authorization"))
+
+	require.NoError(t, os.WriteFile(path, []byte("second-token"), 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+
+	req = httptest.NewRequest(http.MethodGet, "http://prometheus/api/v1/query", nil)
+	require.NoError(t, auth.authenticate(req))
+	assert.Equal(t, "Bearer second-token", req.Header.Get("Authorization"))
+}
+
+func TestBearerFileAuthenticator_MissingFile(t *testing.T) {
+	auth := &bearerFileAuthenticator{path: filepath.Join(t.TempDir(), "missing")}
+	err := auth.authenticate(httptest.NewRequest(http.MethodGet, "http://prometheus/api/v1/query", nil))
+	assert.ErrorContains(t, err, "failed to read bearer token file")
+}
+
+func TestOAuth2Authenticator_CachesToken(t *testing.T) {
+	var tokenRequests atomic.Int32
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests.Add(1)
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "read write", r.PostForm.Get("scope"))
+		clientId, clientSecret, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "extension", clientId)
+		assert.Equal(t, "secret", clientSecret)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token": "token-123", "token_type": "bearer", "expires_in": 3600}`))
+	}))
+	defer idp.Close()
+
+	config := &AuthConfig{Type: authTypeOAuth2, ClientId: "extension", ClientSecret: "secret", TokenUrl: idp.URL, Scopes: []string{"read", "write"}}
+	for range 3 {
+		auth, err := newAuthenticator(config, idp.Client())
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodGet, "http://prometheus/api/v1/query", nil)
+		require.NoError(t, auth.authenticate(req))
+		assert.Equal(t, "Bearer token-123", req.Header.Get("Authorization"))
+	}
+	assert.Equal(t, int32(1), tokenRequests.Load())
+
+	// An expired token is fetched again
+	getOAuth2TokenSource(config).expiry = time.Now().Add(oauth2ExpiryDelta / 2)
+	auth, err := newAuthenticator(config, idp.Client())
+	require.NoError(t, err)
+	require.NoError(t, auth.authenticate(httptest.NewRequest(http.MethodGet, "http://prometheus/api/v1/query", nil)))
+	assert.Equal(t, int32(2), tokenRequests.Load())
+}
+
+func TestOAuth2Authenticator_TokenWithoutExpiry(t *testing.T) {
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token": "token-123", "token_type": "bearer"}`))
+	}))
+	defer idp.Close()
+
+	config := &AuthConfig{Type: authTypeOAuth2, ClientId: "without-expiry", ClientSecret: "secret", TokenUrl: idp.URL}
+	auth, err := newAuthenticator(config, idp.Client())
+	require.NoError(t, err)
+	require.NoError(t, auth.authenticate(httptest.NewRequest(http.MethodGet, "http://prometheus/api/v1/query", nil)))
+	assert.WithinDuration(t, time.Now().Add(oauth2DefaultLifetime), getOAuth2TokenSource(config).expiry, time.Minute)
+}
+
+func TestOAuth2Authenticator_DropsRejectedToken(t *testing.T) {
+	var tokenRequests atomic.Int32
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "bearer", "expires_in": 3600}`, tokenRequests.Add(1))
+	}))
+	defer idp.Close()
+	prometheus := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer prometheus.Close()
+
+	auth, err := newAuthenticator(&AuthConfig{Type: authTypeOAuth2, ClientId: "revoked", ClientSecret: "secret", TokenUrl: idp.URL}, idp.Client())
+	require.NoError(t, err)
+	client := &http.Client{Transport: &authRoundTripper{auth: auth, rt: http.DefaultTransport}}
+	get := func() int {
+		resp, err := client.Get(prometheus.URL)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, get())
+	assert.Equal(t, http.StatusOK, get())
+	assert.Equal(t, http.StatusOK, get())
+	assert.Equal(t, int32(2), tokenRequests.Load())
+}
+
+func TestOAuth2Authenticator_TokenEndpointError(t *testing.T) {
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error": "invalid_client"}`))
+	}))
+	defer idp.Close()
+
+	auth, err := newAuthenticator(&AuthConfig{Type: authTypeOAuth2, ClientId: "extension", ClientSecret: "wrong", TokenUrl: idp.URL}, idp.Client())
+	require.NoError(t, err)
+	err = auth.authenticate(httptest.NewRequest(http.MethodGet, "http://prometheus/api/v1/query", nil))
+	assert.ErrorContains(t, err, "401 Unauthorized")
+	assert.ErrorContains(t, err, "invalid_client")
+}
+
+func TestInstance_GetApiClient_WithAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status": "success", "data": {"resultType": "scalar", "result": [1700000000, "1"]}}`))
+	}))
+	defer server.Close()
+
+	instance := &Instance{Name: "secured", BaseUrl: server.URL, Auth: &AuthConfig{Type: authTypeBasic, Username: "user", Password: "secret"}}
+	client, err := instance.GetApiClient()
+	require.NoError(t, err)
+
+	_, _, err = client.Query(context.Background(), "1", time.Now())
+	assert.NoError(t, err)
+
+	instance.Auth = &AuthConfig{Type: "unknown"}
+	_, err = instance.GetApiClient()
+	assert.ErrorContains(t, err, "invalid auth config of instance 'secured'")
+}
+
+func TestInstance_GetApiClient_WithOAuth2OnOtherHost(t *testing.T) {
+	prometheusCa, idpCa := newTestCA(t), newTestCA(t)
+	dir := t.TempDir()
+	prometheusCaFile, idpCaFile := filepath.Join(dir, "prometheus.pem"), filepath.Join(dir, "idp.pem")
+	writeCertificate(t, prometheusCaFile, prometheusCa)
+	writeCertificate(t, idpCaFile, idpCa)
+	server := newTLSServer(t, prometheusCa, nil)
+
+	// The identity provider is reachable as localhost, the instance as 127.0.0.1, and both use different CAs
+	idpCertificate := newTestCertificate(t, "idp", idpCa, x509.Certificate{
+		DNSNames:    []string{"localhost"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	idp := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token": "token-123", "token_type": "bearer", "expires_in": 3600}`))
+	}))
+	idp.TLS = &tls.Config{Certificates: []tls.Certificate{idpCertificate.tls}}
+	idp.StartTLS()
+	t.Cleanup(idp.Close)
+	tokenUrl := strings.Replace(idp.URL, "127.0.0.1", "localhost", 1)
+
+	t.Run("verifies the token endpoint with its own CA", func(t *testing.T) {
+		assert.NoError(t, query(&Instance{
+			Name:    "oauth2-own-ca",
+			BaseUrl: server.URL,
+			TLS:     &TLSConfig{CaFile: prometheusCaFile},
+			Auth:    &AuthConfig{Type: authTypeOAuth2, ClientId: "own-ca", ClientSecret: "secret", TokenUrl: tokenUrl, TokenCaFile: idpCaFile},
+		}))
+	})
+
+	t.Run("verifies the token endpoint with the system roots", func(t *testing.T) {
+		err := query(&Instance{
+			Name:    "oauth2-system-roots",
+			BaseUrl: server.URL,
+			TLS:     &TLSConfig{CaFile: prometheusCaFile},
+			Auth:    &AuthConfig{Type: authTypeOAuth2, ClientId: "system-roots", ClientSecret: "secret", TokenUrl: tokenUrl},
+		})
+		assert.ErrorContains(t, err, "failed to request oauth2 token")
+		assert.ErrorContains(t, err, "certificate signed by unknown authority")
+	})
+}
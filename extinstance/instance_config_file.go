@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extinstance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"sigs.k8s.io/yaml"
+)
+
+// Duration is a time.Duration which is configured as a string, e.g., 30s.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string like 30s: %w", err)
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// instancesConfigFile is the format of the optional instances config file. Both YAML and JSON are supported, e.g.:
+//
+//	instances:
+//	  - name: prod
+//	    baseUrl: https://prometheus.example.com
+type instancesConfigFile struct {
+	Instances []Instance `json:"instances"`
+}
+
+func parseInstancesConfig(content []byte) ([]Instance, error) {
+	var file instancesConfigFile
+	if err := yaml.UnmarshalStrict(content, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse instances config: %w", err)
+	}
+	for i, instance := range file.Instances {
+		if instance.Name == "" {
+			return nil, fmt.Errorf("instance at index %d has no name", i)
+		}
+		if instance.BaseUrl == "" {
+			return nil, fmt.Errorf("instance '%s' has no baseUrl", instance.Name)
+		}
+		if instance.Auth != nil {
+			if err := instance.Auth.validate(); err != nil {
+				return nil, fmt.Errorf("instance '%s' has an invalid auth config: %w", instance.Name, err)
+			}
+		}
+		for _, failoverUrl := range instance.FailoverUrls {
+			if parsed, err := url.Parse(failoverUrl); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+				return nil, fmt.Errorf("instance '%s' has an invalid failoverUrl '%s'", instance.Name, failoverUrl)
+			}
+		}
+		if instance.HedgeDelay < 0 {
+			return nil, fmt.Errorf("instance '%s' has a negative hedgeDelay", instance.Name)
+		}
+		if instance.RequestTimeout < 0 {
+			return nil, fmt.Errorf("instance '%s' has a negative requestTimeout", instance.Name)
+		}
+		if instance.QueryRetries != nil && *instance.QueryRetries < 0 {
+			return nil, fmt.Errorf("instance '%s' has negative queryRetries", instance.Name)
+		}
+		if instance.SigV4 != nil {
+			if instance.Auth != nil {
+				return nil, fmt.Errorf("instance '%s' can't combine auth and sigv4", instance.Name)
+			}
+			if err := instance.SigV4.validate(); err != nil {
+				return nil, fmt.Errorf("instance '%s' has an invalid sigv4 config: %w", instance.Name, err)
+			}
+		}
+		if instance.Limits != nil {
+			if err := instance.Limits.validate(); err != nil {
+				return nil, fmt.Errorf("instance '%s' has invalid limits: %w", instance.Name, err)
+			}
+		}
+		if instance.TLS != nil {
+			if err := instance.TLS.validate(); err != nil {
+				return nil, fmt.Errorf("instance '%s' has an invalid TLS config: %w", instance.Name, err)
+			}
+		}
+	}
+	return file.Instances, nil
+}
+
+// mergeInstances combines the instances configured through environment variables with the ones of the config file.
+// Instances from the environment take precedence in case of duplicate names.
+func mergeInstances(fromEnv []Instance, fromFile []Instance) ([]Instance, error) {
+	merged := make([]Instance, 0, len(fromEnv)+len(fromFile))
+	names := make(map[string]bool, len(fromEnv)+len(fromFile))
+	var errs []error
+	for _, instance := range slices.Concat(fromEnv, fromFile) {
+		if names[instance.Name] {
+			errs = append(errs, fmt.Errorf("duplicate instance name '%s'", instance.Name))
+			continue
+		}
+		names[instance.Name] = true
+		merged = append(merged, instance)
+	}
+	return merged, errors.Join(errs...)
+}
+
+// WatchInstancesConfigFile loads the instances from the given file and keeps them up to date by polling the file for
+// changes in the given interval. Polling also picks up mounted secrets and config maps, which Kubernetes updates by
+// swapping a symlink rather than writing the file. An invalid file is rejected during the initial load, later changes
+// to an invalid file are logged and the previously loaded instances are kept.
+func WatchInstancesConfigFile(ctx context.Context, path string, interval time.Duration) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := applyInstancesConfig(content); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				current, err := os.ReadFile(path)
+				if err != nil {
+					log.Warn().Err(err).Str("path", path).Msg("Failed to read instances config file, keeping the current instances.")
+					continue
+				}
+				if bytes.Equal(current, content) {
+					continue
+				}
+				content = current
+				if err := applyInstancesConfig(content); err != nil {
+					log.Error().Err(err).Str("path", path).Msg("Invalid instances config file, keeping the current instances.")
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func applyInstancesConfig(content []byte) error {
+	fromFile, err := parseInstancesConfig(content)
+	if err != nil {
+		return err
+	}
+	merged, err := mergeInstances(envInstances, fromFile)
+	if err != nil {
+		log.Warn().Err(err).Msg("Ignoring duplicate Prometheus instances.")
+	}
+	SetInstances(merged)
+	log.Info().Int("instances", len(merged)).Msg("Loaded Prometheus instances.")
+	return nil
+}
@@ -0,0 +1,187 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extinstance
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInstancesConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		expected  []Instance
+		wantError string
+	}{
+		{
+			name: "yaml",
+			content: `
+instances:
+  - name: prod
+    baseUrl: http://prometheus-prod:9090
+    headerKey: Authorization
+    headerValue: Bearer token
+  - name: staging
+    baseUrl: http://prometheus-staging:9090
+`,
+			expected: []Instance{
+				{Name: "prod", BaseUrl: "http://prometheus-prod:9090", HeaderKey: "Authorization", HeaderValue: "Bearer token"},
+				{Name: "staging", BaseUrl: "http://prometheus-staging:9090"},
+			},
+		},
+		{
+			name: "request settings",
+			content: `
+instances:
+  - name: victoria-metrics
+    baseUrl: http://victoria-metrics:8428
+    requestParams:
+      latency_offset: 1s
+    requestTimeout: 30s
+    queryRetries: 0
+`,
+			expected: []Instance{{
+				Name:           "victoria-metrics",
+				BaseUrl:        "http://victoria-metrics:8428",
+				RequestParams:  map[string]string{"latency_offset": "1s"},
+				RequestTimeout: Duration(30 * time.Second),
+				QueryRetries:   new(0),
+			}},
+		},
+		{
+			name: "failover",
+			content: `
+instances:
+  - name: ha
+    baseUrl: http://prometheus-0:9090
+    failoverUrls:
+      - http://prometheus-1:9090
+    hedgeDelay: 2s
+`,
+			expected: []Instance{{
+				Name:         "ha",
+				BaseUrl:      "http://prometheus-0:9090",
+				FailoverUrls: []string{"http://prometheus-1:9090"},
+				HedgeDelay:   Duration(2 * time.Second),
+			}},
+		},
+		{
+			name:      "invalid failover url",
+			content:   `{"instances": [{"name": "ha", "baseUrl": "http://prometheus-0:9090", "failoverUrls": ["prometheus-1"]}]}`,
+			wantError: "invalid failoverUrl 'prometheus-1'",
+		},
+		{
+			name:      "invalid request timeout",
+			content:   `{"instances": [{"name": "prod", "baseUrl": "http://prometheus-prod:9090", "requestTimeout": "soon"}]}`,
+			wantError: "invalid duration",
+		},
+		{
+			name:      "negative query retries",
+			content:   `{"instances": [{"name": "prod", "baseUrl": "http://prometheus-prod:9090", "queryRetries": -1}]}`,
+			wantError: "negative queryRetries",
+		},
+		{
+			name:     "json",
+			content:  `{"instances": [{"name": "prod", "baseUrl": "http://prometheus-prod:9090"}]}`,
+			expected: []Instance{{Name: "prod", BaseUrl: "http://prometheus-prod:9090"}},
+		},
+		{
+			name:      "missing name",
+			content:   `{"instances": [{"baseUrl": "http://prometheus-prod:9090"}]}`,
+			wantError: "instance at index 0 has no name",
+		},
+		{
+			name:      "missing baseUrl",
+			content:   `{"instances": [{"name": "prod"}]}`,
+			wantError: "instance 'prod' has no baseUrl",
+		},
+		{
+			name:      "unknown field",
+			content:   `{"instances": [{"name": "prod", "url": "http://prometheus-prod:9090"}]}`,
+			wantError: "unknown field",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseInstancesConfig([]byte(tt.content))
+			if tt.wantError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestMergeInstances(t *testing.T) {
+	merged, err := mergeInstances(
+		[]Instance{{Name: "prod", BaseUrl: "http://from-env:9090"}},
+		[]Instance{{Name: "prod", BaseUrl: "http://from-file:9090"}, {Name: "staging", BaseUrl: "http://staging:9090"}},
+	)
+
+	assert.ErrorContains(t, err, "duplicate instance name 'prod'")
+	assert.Equal(t, []Instance{
+		{Name: "prod", BaseUrl: "http://from-env:9090"},
+		{Name: "staging", BaseUrl: "http://staging:9090"},
+	}, merged)
+}
+
+func TestWatchInstancesConfigFile(t *testing.T) {
+	originalInstances := GetInstances()
+	defer SetInstances(originalInstances)
+	originalEnvInstances := envInstances
+	defer func() { envInstances = originalEnvInstances }()
+	envInstances = []Instance{{Name: "from-env", BaseUrl: "http://from-env:9090"}}
+
+	path := filepath.Join(t.TempDir(), "instances.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("instances:\n  - name: prod\n    baseUrl: http://prod:9090\n"), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, WatchInstancesConfigFile(ctx, path, 10*time.Millisecond))
+	assert.Equal(t, []Instance{
+		{Name: "from-env", BaseUrl: "http://from-env:9090"},
+		{Name: "prod", BaseUrl: "http://prod:9090"},
+	}, GetInstances())
+
+	t.Run("applies changes", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("instances:\n  - name: staging\n    baseUrl: http://staging:9090\n"), 0o600))
+		assert.Eventually(t, func() bool {
+			_, err := FindInstanceByName("staging")
+			return err == nil
+		}, time.Second, 10*time.Millisecond)
+		_, err := FindInstanceByName("prod")
+		assert.Error(t, err)
+	})
+
+	t.Run("keeps instances on invalid changes", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("instances:\n  - name: broken\n"), 0o600))
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, []Instance{
+			{Name: "from-env", BaseUrl: "http://from-env:9090"},
+			{Name: "staging", BaseUrl: "http://staging:9090"},
+		}, GetInstances())
+	})
+}
+
+func TestWatchInstancesConfigFile_InvalidInitialFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "instances.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("instances: [{name: prod}]"), 0o600))
+
+	err := WatchInstancesConfigFile(context.Background(), path, time.Second)
+	assert.ErrorContains(t, err, "has no baseUrl")
+
+	err = WatchInstancesConfigFile(context.Background(), filepath.Join(t.TempDir(), "missing.yaml"), time.Second)
+	assert.Error(t, err)
+}
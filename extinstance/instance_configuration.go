@@ -5,31 +5,122 @@ package extinstance
 
 import (
 	"bytes"
-	"crypto/tls"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/api"
 	prometheus "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/rs/zerolog/log"
 	"github.com/steadybit/extension-prometheus/v2/config"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
+// defaultTenantHeader is the tenant header of Grafana Mimir and Cortex.
+const defaultTenantHeader = "X-Scope-OrgID"
+
 type Instance struct {
 	Name        string `json:"name"`
 	BaseUrl     string `json:"baseUrl"`
 	HeaderKey   string `json:"headerKey"`
 	HeaderValue string `json:"headerValue"`
+	// FailoverUrls are the URLs of further replicas, e.g., of a Prometheus HA pair. Requests fail over to them if the
+	// replica at BaseUrl errors.
+	FailoverUrls []string `json:"failoverUrls,omitempty"`
+	// HedgeDelay enables hedged requests, sending a request to the next replica as well if a replica didn't answer
+	// within the delay.
+	HedgeDelay Duration `json:"hedgeDelay,omitempty"`
+	// Headers are additional static headers sent with each request.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Tenant is sent in the TenantHeader, e.g., to select a Grafana Mimir, Cortex or Thanos tenant.
+	Tenant       string `json:"tenant,omitempty"`
+	TenantHeader string `json:"tenantHeader,omitempty"`
+	// RequestParams are added to each request, in addition to and overriding the global additional request params.
+	RequestParams map[string]string `json:"requestParams,omitempty"`
+	// RequestTimeout and QueryRetries override the global settings if set.
+	RequestTimeout Duration `json:"requestTimeout,omitempty"`
+	QueryRetries   *int     `json:"queryRetries,omitempty"`
+	// Auth optionally configures an authentication provider, in addition to the static header.
+	Auth *AuthConfig `json:"auth,omitempty"`
+	// TLS optionally configures CAs, client certificates and other TLS settings of this instance.
+	TLS *TLSConfig `json:"tls,omitempty"`
+	// SigV4 optionally signs all requests with AWS Signature Version 4.
+	SigV4 *SigV4Config `json:"sigv4,omitempty"`
+	// Limits optionally override the global concurrency, rate and circuit breaker settings.
+	Limits *LimitsConfig `json:"limits,omitempty"`
+	// SkipQueryValidation overrides the global setting whether PromQL expressions are validated, e.g., for an instance
+	// with a different dialect.
+	SkipQueryValidation *bool `json:"skipQueryValidation,omitempty"`
 }
 
 func (i *Instance) IsAuthenticated() bool {
 	return len(i.HeaderKey) > 0 && len(i.HeaderValue) > 0
 }
 
+func (i *Instance) tenantHeader() string {
+	if i.TenantHeader == "" {
+		return defaultTenantHeader
+	}
+	return i.TenantHeader
+}
+
+// GetRequestTimeout returns the request timeout of this instance, defaulting to the global request timeout.
+func (i *Instance) GetRequestTimeout() time.Duration {
+	if i.RequestTimeout > 0 {
+		return time.Duration(i.RequestTimeout)
+	}
+	return config.Config.RequestTimeout
+}
+
+// GetQueryRetries returns how often failed queries against this instance are retried, defaulting to the global
+// query retries.
+func (i *Instance) GetQueryRetries() int {
+	if i.QueryRetries != nil {
+		return *i.QueryRetries
+	}
+	return config.Config.QueryRetries
+}
+
+// ValidatesQueries tells whether PromQL expressions for this instance are validated with the parser of Prometheus. It
+// defaults to the global setting, but instances discovered as VictoriaMetrics aren't validated, as MetricsQL extends
+// PromQL.
+func (i *Instance) ValidatesQueries(flavour string) bool {
+	if i.SkipQueryValidation != nil {
+		return !*i.SkipQueryValidation
+	}
+	return !config.Config.SkipQueryValidation && flavour != FlavourVictoriaMetrics
+}
+
+// getRequestParams merges the global additional request params with the ones of this instance.
+func (i *Instance) getRequestParams() url.Values {
+	params := url.Values{}
+	for n := 0; n+1 < len(config.Config.AdditionalRequestParams); n += 2 {
+		params.Add(config.Config.AdditionalRequestParams[n], config.Config.AdditionalRequestParams[n+1])
+	}
+	for key, value := range i.RequestParams {
+		params.Set(key, value)
+	}
+	return params
+}
+
+// WithTenant returns a copy of the instance which sends the given tenant instead of the configured one.
+func (i *Instance) WithTenant(tenant string) *Instance {
+	instance := *i
+	instance.Tenant = tenant
+	return &instance
+}
+
 // headerRoundTripper is a custom transport that adds headers to each request
 type headerRoundTripper struct {
 	headers map[string][]string
@@ -47,16 +138,16 @@ func (h *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error
 }
 
 type paramRoundTripper struct {
-	params []string
+	params url.Values
 	rt     http.RoundTripper
 }
 
 func (p *paramRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	q := req.URL.Query()
-	for i := 0; i < len(config.Config.AdditionalRequestParams); i += 2 {
-		key := config.Config.AdditionalRequestParams[i]
-		value := config.Config.AdditionalRequestParams[i+1]
-		q.Add(key, value)
+	for key, values := range p.params {
+		for _, value := range values {
+			q.Add(key, value)
+		}
 	}
 	req.URL.RawQuery = q.Encode()
 	return p.rt.RoundTrip(req)
@@ -100,73 +191,308 @@ func (l *loggingRoundTripper) RoundTrip(req *http.Request) (*http.Response, erro
 	return resp, nil
 }
 
+// apiClient is a cached API client together with its transport, so idle connections can be closed on eviction.
+type apiClient struct {
+	api        prometheus.API
+	client     api.Client
+	transports []*http.Transport
+	// fingerprint identifies the configuration the client was created from.
+	fingerprint string
+	// lastUsed is the time in unix nanoseconds the client was last handed out.
+	lastUsed atomic.Int64
+}
+
+func (c *apiClient) closeIdleConnections() {
+	for _, transport := range c.transports {
+		transport.CloseIdleConnections()
+	}
+}
+
+// apiClientIdleTimeout is the time after which unused API clients are evicted, e.g., of a tenant only a single
+// experiment queried.
+const apiClientIdleTimeout = 10 * time.Minute
+
+// apiClients caches the API clients by instance name and tenant. Reusing the transport keeps connections alive across
+// queries, instead of dialing and doing a TLS handshake for each of them.
+var apiClients sync.Map
+
+// lastApiClientEviction is the time in unix nanoseconds idle API clients were last evicted.
+var lastApiClientEviction atomic.Int64
+
+type apiClientKey struct {
+	instance string
+	tenant   string
+}
+
+// apiClientFingerprint identifies the configuration an API client is created from, including the global settings. It
+// is hashed, so the cache doesn't keep another copy of the credentials.
+func (i *Instance) apiClientFingerprint() (string, error) {
+	configuration, err := json.Marshal(struct {
+		Instance             *Instance
+		InsecureSkipVerify   bool
+		EnableRequestLogging bool
+		RequestParams        []string
+		RequestTimeout       time.Duration
+		MaxIdleConnsPerHost  int
+		MaxConnsPerHost      int
+		IdleConnTimeout      time.Duration
+		Limits               LimitsConfig
+	}{
+		Instance:             i,
+		InsecureSkipVerify:   config.Config.InsecureSkipVerify,
+		EnableRequestLogging: config.Config.EnableRequestLogging,
+		RequestParams:        config.Config.AdditionalRequestParams,
+		RequestTimeout:       config.Config.RequestTimeout,
+		MaxIdleConnsPerHost:  config.Config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:      config.Config.MaxConnsPerHost,
+		IdleConnTimeout:      config.Config.IdleConnTimeout,
+		Limits:               i.getLimits(),
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(configuration)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// GetApiClient returns the API client of this instance. Clients are shared by all callers with the same instance
+// configuration, e.g., all experiments querying the same instance and tenant.
 func (i *Instance) GetApiClient() (prometheus.API, error) {
+	client, err := i.getApiClient()
+	if err != nil {
+		return nil, err
+	}
+	return client.api, nil
+}
+
+func (i *Instance) getApiClient() (*apiClient, error) {
+	now := time.Now()
+	evictIdleApiClients(now)
+
+	fingerprint, err := i.apiClientFingerprint()
+	if err != nil {
+		return nil, err
+	}
+	key := apiClientKey{instance: i.Name, tenant: i.Tenant}
+	if cached, ok := apiClients.Load(key); ok && cached.(*apiClient).fingerprint == fingerprint {
+		client := cached.(*apiClient)
+		client.lastUsed.Store(now.UnixNano())
+		return client, nil
+	}
+
+	// Without a cached client or with a changed configuration, e.g., of an instance not loaded through SetInstances.
+	client, err := i.newApiClient()
+	if err != nil {
+		return nil, err
+	}
+	client.fingerprint = fingerprint
+	client.lastUsed.Store(now.UnixNano())
+	if previous, loaded := apiClients.Swap(key, client); loaded {
+		previous.(*apiClient).closeIdleConnections()
+	}
+	return client, nil
+}
 
+// evictIdleApiClients evicts the API clients which weren't used for apiClientIdleTimeout and closes their idle
+// connections. It checks at most once a minute.
+func evictIdleApiClients(now time.Time) {
+	last := lastApiClientEviction.Load()
+	if now.UnixNano()-last < int64(time.Minute) || !lastApiClientEviction.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	apiClients.Range(func(key, value any) bool {
+		client := value.(*apiClient)
+		if now.Sub(time.Unix(0, client.lastUsed.Load())) > apiClientIdleTimeout && apiClients.CompareAndDelete(key, value) {
+			client.closeIdleConnections()
+		}
+		return true
+	})
+}
+
+// resetApiClients evicts all cached API clients and closes their idle connections.
+func resetApiClients() {
+	apiClients.Range(func(key, value any) bool {
+		apiClients.Delete(key)
+		value.(*apiClient).closeIdleConnections()
+		return true
+	})
+}
+
+func (i *Instance) newApiClient() (*apiClient, error) {
 	headers := map[string][]string{
 		"User-Agent": {"steadybit-extension-prometheus"},
 	}
 
+	for key, value := range i.Headers {
+		headers[key] = []string{value}
+	}
 	if i.IsAuthenticated() {
 		headers[i.HeaderKey] = []string{i.HeaderValue}
 	}
-
-	transport := http.Transport{
-		ResponseHeaderTimeout: config.Config.RequestTimeout,
-		DialContext: (&net.Dialer{
-			Timeout:   5 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-		TLSHandshakeTimeout: 5 * time.Second,
-		Proxy:               http.ProxyFromEnvironment,
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: config.Config.InsecureSkipVerify,
-		},
+	if i.Tenant != "" {
+		headers[i.tenantHeader()] = []string{i.Tenant}
 	}
 
-	var rt http.RoundTripper = &transport
-	if config.Config.EnableRequestLogging {
-		rt = &loggingRoundTripper{
-			rt: rt,
+	origins, err := i.getOrigins()
+	if err != nil {
+		return nil, err
+	}
+	var transports []*http.Transport
+	failover := &failoverRoundTripper{
+		basePath:   strings.TrimSuffix(origins[0].Path, "/"),
+		hedgeDelay: time.Duration(i.HedgeDelay),
+	}
+	for _, origin := range origins {
+		transport, rt, err := i.newOriginRoundTripper(origin)
+		if err != nil {
+			return nil, err
 		}
+		transports = append(transports, transport)
+		failover.origins = append(failover.origins, failoverOrigin{url: origin, rt: rt})
+	}
+
+	rt := failover.origins[0].rt
+	if len(failover.origins) > 1 {
+		rt = failover
 	}
-	if len(config.Config.AdditionalRequestParams) > 0 {
+	if params := i.getRequestParams(); len(params) > 0 {
 		rt = &paramRoundTripper{
-			params: config.Config.AdditionalRequestParams,
+			params: params,
 			rt:     rt,
 		}
 	}
+	if i.Auth != nil {
+		var tokenClient *http.Client
+		if i.Auth.Type == authTypeOAuth2 {
+			transport, err := newOAuth2Transport(i.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth config of instance '%s': %w", i.Name, err)
+			}
+			transports = append(transports, transport)
+			tokenClient = &http.Client{Transport: transport, Timeout: i.GetRequestTimeout()}
+		}
+		auth, err := newAuthenticator(i.Auth, tokenClient)
+		if err != nil {
+			return nil, fmt.Errorf("invalid auth config of instance '%s': %w", i.Name, err)
+		}
+		rt = &authRoundTripper{
+			auth: auth,
+			rt:   rt,
+		}
+	}
 	rt = &headerRoundTripper{
 		headers: headers,
 		rt:      rt,
 	}
+	rt = &metricsRoundTripper{
+		instance: i.Name,
+		basePath: failover.basePath,
+		rt:       rt,
+	}
+	rt = &limitRoundTripper{
+		limiter: getInstanceLimiter(i),
+		rt:      rt,
+	}
 
-	apiClient, err := api.NewClient(api.Config{
+	client, err := api.NewClient(api.Config{
 		Address:      i.BaseUrl,
 		RoundTripper: rt,
 	})
 	if err != nil {
 		return nil, err
 	}
-	client := prometheus.NewAPI(apiClient)
-	return client, nil
+	return &apiClient{api: prometheus.NewAPI(client), client: client, transports: transports}, nil
+}
+
+// newOriginRoundTripper creates the transport to a single replica of the instance. TLS is verified against the host
+// of the replica and requests are signed for it.
+func (i *Instance) newOriginRoundTripper(origin *url.URL) (*http.Transport, http.RoundTripper, error) {
+	tlsConfig, err := newTLSClientConfig(i.TLS, origin.Hostname(), config.Config.InsecureSkipVerify)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid TLS config of instance '%s': %w", i.Name, err)
+	}
+
+	transport := &http.Transport{
+		ResponseHeaderTimeout: i.GetRequestTimeout(),
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+		Proxy:               http.ProxyFromEnvironment,
+		TLSClientConfig:     tlsConfig,
+		MaxIdleConnsPerHost: config.Config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     config.Config.MaxConnsPerHost,
+		IdleConnTimeout:     config.Config.IdleConnTimeout,
+	}
+
+	// The request is traced per replica, and the trace context is propagated to it.
+	var rt http.RoundTripper = otelhttp.NewTransport(transport, otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+		return req.Method + " " + strings.TrimPrefix(req.URL.Path, strings.TrimSuffix(origin.Path, "/"))
+	}))
+	if config.Config.EnableRequestLogging {
+		rt = &loggingRoundTripper{
+			rt: rt,
+		}
+	}
+	if i.SigV4 != nil {
+		// The signature covers all headers and parameters, so signing must happen after all of them are added.
+		rt, err = newSigV4RoundTripper(i.SigV4, rt)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid sigv4 config of instance '%s': %w", i.Name, err)
+		}
+	}
+	return transport, rt, nil
 }
 
 var (
-	Instances []Instance
+	instances atomic.Pointer[[]Instance]
+	// envInstances are the instances configured through environment variables. They are always present, in addition
+	// to the instances of the optional instances config file.
+	envInstances []Instance
 )
 
 func init() {
 	name := getInstanceName(0)
 	for len(name) > 0 {
-		index := len(Instances)
-		Instances = append(Instances, Instance{
-			Name:        name,
-			BaseUrl:     getInstanceOrigin(index),
-			HeaderKey:   getAuthHeaderKey(index),
-			HeaderValue: getAuthHeaderValue(index),
+		index := len(envInstances)
+		envInstances = append(envInstances, Instance{
+			Name:                name,
+			BaseUrl:             getInstanceOrigin(index),
+			FailoverUrls:        getFailoverOrigins(index),
+			HedgeDelay:          getHedgeDelay(index),
+			HeaderKey:           getAuthHeaderKey(index),
+			HeaderValue:         getAuthHeaderValue(index),
+			Headers:             getHeaders(index),
+			Tenant:              getTenant(index),
+			TenantHeader:        getTenantHeader(index),
+			Auth:                getAuthConfig(index),
+			TLS:                 getTLSConfig(index),
+			SigV4:               getSigV4Config(index),
+			Limits:              getLimitsConfig(index),
+			RequestParams:       getRequestParams(index),
+			RequestTimeout:      getRequestTimeout(index),
+			QueryRetries:        getQueryRetries(index),
+			SkipQueryValidation: getSkipQueryValidation(index),
 		})
-		name = getInstanceName(len(Instances))
+		name = getInstanceName(len(envInstances))
 	}
+	SetInstances(envInstances)
+}
+
+// GetInstances returns the currently configured instances. The returned slice must not be modified.
+func GetInstances() []Instance {
+	if current := instances.Load(); current != nil {
+		return *current
+	}
+	return nil
+}
+
+// SetInstances atomically replaces the configured instances. Cached API clients are evicted, so connections of
+// removed or changed instances are closed.
+func SetInstances(newInstances []Instance) {
+	instances.Store(&newInstances)
+	resetApiClients()
 }
 
 func getInstanceName(n int) string {
@@ -177,6 +503,25 @@ func getInstanceOrigin(n int) string {
 	return os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_ORIGIN", n))
 }
 
+// getFailoverOrigins parses the origins of further replicas given as comma separated URLs.
+func getFailoverOrigins(n int) []string {
+	value := os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_FAILOVER_ORIGINS", n))
+	if value == "" {
+		return nil
+	}
+	var origins []string
+	for _, origin := range strings.Split(value, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+func getHedgeDelay(n int) Duration {
+	return getDuration(n, "HEDGE_DELAY", "hedge delay")
+}
+
 func getAuthHeaderKey(n int) string {
 	return os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_HEADER_KEY", n))
 }
@@ -185,8 +530,197 @@ func getAuthHeaderValue(n int) string {
 	return os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_HEADER_VALUE", n))
 }
 
+func getTenant(n int) string {
+	return os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_TENANT", n))
+}
+
+func getTenantHeader(n int) string {
+	return os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_TENANT_HEADER", n))
+}
+
+// getHeaders parses additional headers given as comma separated key:value pairs, e.g., X-Foo:bar,X-Baz:qux. Commas in
+// values are escaped with a backslash, e.g., Accept:text/plain\,application/json.
+func getHeaders(n int) map[string]string {
+	return parseKeyValuePairs(n, os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_HEADERS", n)))
+}
+
+func parseKeyValuePairs(n int, value string) map[string]string {
+	if value == "" {
+		return nil
+	}
+	pairs := make(map[string]string)
+	for _, pair := range splitUnescaped(value, ',') {
+		key, pairValue, found := strings.Cut(pair, ":")
+		if !found || strings.TrimSpace(key) == "" {
+			log.Warn().Int("instance", n).Msgf("Ignoring invalid pair '%s', expected key:value.", pair)
+			continue
+		}
+		pairs[strings.TrimSpace(key)] = strings.TrimSpace(pairValue)
+	}
+	return pairs
+}
+
+// splitUnescaped splits the value at each separator not escaped with a backslash. Escaped separators and backslashes are
+// unescaped, other backslashes are kept, e.g., in Windows paths.
+func splitUnescaped(value string, sep byte) []string {
+	var parts []string
+	var part strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value) && (value[i+1] == sep || value[i+1] == '\\'):
+			i++
+			part.WriteByte(value[i])
+		case value[i] == sep:
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(value[i])
+		}
+	}
+	return append(parts, part.String())
+}
+
+// getRequestParams parses request params given as comma separated key:value pairs, e.g., latency_offset:1s.
+func getRequestParams(n int) map[string]string {
+	return parseKeyValuePairs(n, os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_REQUEST_PARAMS", n)))
+}
+
+func getRequestTimeout(n int) Duration {
+	return getDuration(n, "REQUEST_TIMEOUT", "request timeout")
+}
+
+func getDuration(n int, key string, name string) Duration {
+	value := os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_%s", n, key))
+	if value == "" {
+		return 0
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Warn().Int("instance", n).Msgf("Ignoring invalid %s '%s'.", name, value)
+		return 0
+	}
+	return Duration(duration)
+}
+
+func getQueryRetries(n int) *int {
+	value := os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_QUERY_RETRIES", n))
+	if value == "" {
+		return nil
+	}
+	retries, err := strconv.Atoi(value)
+	if err != nil || retries < 0 {
+		log.Warn().Int("instance", n).Msgf("Ignoring invalid query retries '%s'.", value)
+		return nil
+	}
+	return &retries
+}
+
+func getSkipQueryValidation(n int) *bool {
+	value := os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_SKIP_QUERY_VALIDATION", n))
+	if value == "" {
+		return nil
+	}
+	skip, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Warn().Int("instance", n).Msgf("Ignoring invalid skip query validation '%s'.", value)
+		return nil
+	}
+	return &skip
+}
+
+func getAuthConfig(n int) *AuthConfig {
+	authType := os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_AUTH_TYPE", n))
+	if authType == "" {
+		return nil
+	}
+	auth := &AuthConfig{
+		Type:         authType,
+		Username:     os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_AUTH_USERNAME", n)),
+		Password:     os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_AUTH_PASSWORD", n)),
+		TokenFile:    os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_AUTH_TOKEN_FILE", n)),
+		ClientId:     os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_AUTH_CLIENT_ID", n)),
+		ClientSecret: os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_AUTH_CLIENT_SECRET", n)),
+		TokenUrl:     os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_AUTH_TOKEN_URL", n)),
+		TokenCaFile:  os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_AUTH_TOKEN_CA_FILE", n)),
+	}
+	if scopes := os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_AUTH_SCOPES", n)); scopes != "" {
+		auth.Scopes = strings.Split(scopes, ",")
+	}
+	return auth
+}
+
+func getTLSConfig(n int) *TLSConfig {
+	tlsConfig := &TLSConfig{
+		CaFile:             os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_TLS_CA_FILE", n)),
+		CertFile:           os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_TLS_CERT_FILE", n)),
+		KeyFile:            os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_TLS_KEY_FILE", n)),
+		ServerName:         os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_TLS_SERVER_NAME", n)),
+		MinVersion:         os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_TLS_MIN_VERSION", n)),
+		InsecureSkipVerify: os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_TLS_INSECURE_SKIP_VERIFY", n)) == "true",
+	}
+	if *tlsConfig == (TLSConfig{}) {
+		return nil
+	}
+	return tlsConfig
+}
+
+func getSigV4Config(n int) *SigV4Config {
+	region := os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_SIGV4_REGION", n))
+	if region == "" {
+		return nil
+	}
+	return &SigV4Config{
+		Region:          region,
+		Service:         os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_SIGV4_SERVICE", n)),
+		AccessKeyId:     os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_SIGV4_ACCESS_KEY_ID", n)),
+		SecretAccessKey: os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_SIGV4_SECRET_ACCESS_KEY", n)),
+		SessionToken:    os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_SIGV4_SESSION_TOKEN", n)),
+		Profile:         os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_SIGV4_PROFILE", n)),
+	}
+}
+
+func getLimitsConfig(n int) *LimitsConfig {
+	limits := &LimitsConfig{
+		MaxConcurrentRequests:      getPositiveInt(n, "MAX_CONCURRENT_REQUESTS", "max concurrent requests"),
+		RequestsPerSecond:          getPositiveFloat(n, "REQUESTS_PER_SECOND", "requests per second"),
+		RequestsBurst:              getPositiveInt(n, "REQUESTS_BURST", "requests burst"),
+		CircuitBreakerThreshold:    getPositiveInt(n, "CIRCUIT_BREAKER_THRESHOLD", "circuit breaker threshold"),
+		CircuitBreakerOpenDuration: getDuration(n, "CIRCUIT_BREAKER_OPEN_DURATION", "circuit breaker open duration"),
+	}
+	if *limits == (LimitsConfig{}) {
+		return nil
+	}
+	return limits
+}
+
+func getPositiveInt(n int, key string, name string) int {
+	value := os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_%s", n, key))
+	if value == "" {
+		return 0
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number <= 0 {
+		log.Warn().Int("instance", n).Msgf("Ignoring invalid %s '%s'.", name, value)
+		return 0
+	}
+	return number
+}
+
+func getPositiveFloat(n int, key string, name string) float64 {
+	value := os.Getenv(fmt.Sprintf("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_%d_%s", n, key))
+	if value == "" {
+		return 0
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number <= 0 {
+		log.Warn().Int("instance", n).Msgf("Ignoring invalid %s '%s'.", name, value)
+		return 0
+	}
+	return number
+}
+
 func FindInstanceByName(name string) (*Instance, error) {
-	for _, i := range Instances {
+	for _, i := range GetInstances() {
 		if i.Name == name {
 			return &i, nil
 		}
@@ -4,10 +4,15 @@
 package extinstance
 
 import (
+	"context"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/steadybit/extension-prometheus/v2/config"
 	"github.com/stretchr/testify/assert"
@@ -143,13 +148,13 @@ func TestInstance_GetApiClient_WithLogging(t *testing.T) {
 }
 
 func TestFindInstanceByName(t *testing.T) {
-	originalInstances := Instances
-	defer func() { Instances = originalInstances }()
+	originalInstances := GetInstances()
+	defer SetInstances(originalInstances)
 
-	Instances = []Instance{
+	SetInstances([]Instance{
 		{Name: "prometheus-1", BaseUrl: "http://localhost:9090"},
 		{Name: "prometheus-2", BaseUrl: "http://localhost:9091"},
-	}
+	})
 
 	t.Run("found", func(t *testing.T) {
 		instance, err := FindInstanceByName("prometheus-1")
@@ -165,3 +170,253 @@ func TestFindInstanceByName(t *testing.T) {
 		assert.Equal(t, "not found", err.Error())
 	})
 }
+
+func TestInstance_GetApiClient_WithHeadersAndTenant(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status": "success", "data": {"resultType": "vector", "result": []}}`))
+	}))
+	defer server.Close()
+
+	instance := &Instance{
+		Name:        "mimir",
+		BaseUrl:     server.URL,
+		HeaderKey:   "Authorization",
+		HeaderValue: "Bearer token",
+		Headers:     map[string]string{"X-Team": "checkout"},
+		Tenant:      "team-a",
+	}
+
+	query := func(instance *Instance) {
+		client, err := instance.GetApiClient()
+		require.NoError(t, err)
+		_, _, err = client.Query(context.Background(), "up", time.Now())
+		require.NoError(t, err)
+	}
+
+	query(instance)
+	assert.Equal(t, "Bearer token", received.Get("Authorization"))
+	assert.Equal(t, "checkout", received.Get("X-Team"))
+	assert.Equal(t, "team-a", received.Get("X-Scope-OrgID"))
+
+	query(instance.WithTenant("team-b"))
+	assert.Equal(t, "team-b", received.Get("X-Scope-OrgID"))
+	assert.Equal(t, "team-a", instance.Tenant)
+
+	instance.TenantHeader = "THANOS-TENANT"
+	query(instance)
+	assert.Equal(t, "team-a", received.Get("THANOS-TENANT"))
+	assert.Empty(t, received.Get("X-Scope-OrgID"))
+}
+
+func TestGetHeaders(t *testing.T) {
+	t.Setenv("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_7_HEADERS", "X-Scope-OrgID:team-a, X-Team: checkout ,invalid")
+	assert.Equal(t, map[string]string{"X-Scope-OrgID": "team-a", "X-Team": "checkout"}, getHeaders(7))
+	assert.Nil(t, getHeaders(8))
+
+	t.Setenv("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_7_HEADERS", `Accept:text/plain\,application/json,X-Origin:https://grafana:3000,X-Path:C:\temp\\`)
+	assert.Equal(t, map[string]string{
+		"Accept":   "text/plain,application/json",
+		"X-Origin": "https://grafana:3000",
+		"X-Path":   `C:\temp\`,
+	}, getHeaders(7))
+}
+
+func TestGetSkipQueryValidation(t *testing.T) {
+	t.Setenv("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_7_SKIP_QUERY_VALIDATION", "true")
+	t.Setenv("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_8_SKIP_QUERY_VALIDATION", "sometimes")
+	assert.Equal(t, new(true), getSkipQueryValidation(7))
+	assert.Nil(t, getSkipQueryValidation(8))
+	assert.Nil(t, getSkipQueryValidation(9))
+}
+
+func TestGetLimitsConfig(t *testing.T) {
+	t.Setenv("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_7_MAX_CONCURRENT_REQUESTS", "8")
+	t.Setenv("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_7_REQUESTS_PER_SECOND", "2.5")
+	t.Setenv("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_7_REQUESTS_BURST", "-1")
+	t.Setenv("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_7_CIRCUIT_BREAKER_THRESHOLD", "5")
+	t.Setenv("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_7_CIRCUIT_BREAKER_OPEN_DURATION", "1m")
+	assert.Equal(t, &LimitsConfig{
+		MaxConcurrentRequests:      8,
+		RequestsPerSecond:          2.5,
+		CircuitBreakerThreshold:    5,
+		CircuitBreakerOpenDuration: Duration(time.Minute),
+	}, getLimitsConfig(7))
+	assert.Nil(t, getLimitsConfig(8))
+}
+
+func TestInstance_RequestSettings(t *testing.T) {
+	prevConfig := config.Config
+	t.Cleanup(func() {
+		config.Config = prevConfig
+	})
+	config.Config = config.Specification{
+		AdditionalRequestParams: []string{"latency_offset", "1s", "nocache", "1"},
+		RequestTimeout:          10 * time.Second,
+		QueryRetries:            2,
+	}
+
+	var received url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status": "success", "data": {"resultType": "vector", "result": []}}`))
+	}))
+	defer server.Close()
+
+	t.Run("global defaults", func(t *testing.T) {
+		instance := &Instance{Name: "prometheus", BaseUrl: server.URL}
+		assert.Equal(t, 10*time.Second, instance.GetRequestTimeout())
+		assert.Equal(t, 2, instance.GetQueryRetries())
+
+		client, err := instance.GetApiClient()
+		require.NoError(t, err)
+		_, _, err = client.Query(context.Background(), "up", time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, "1s", received.Get("latency_offset"))
+		assert.Equal(t, "1", received.Get("nocache"))
+	})
+
+	t.Run("instance overrides", func(t *testing.T) {
+		instance := &Instance{
+			Name:           "victoria-metrics",
+			BaseUrl:        server.URL,
+			RequestParams:  map[string]string{"latency_offset": "30s", "extra_label": "env=prod"},
+			RequestTimeout: Duration(time.Minute),
+			QueryRetries:   new(0),
+		}
+		assert.Equal(t, time.Minute, instance.GetRequestTimeout())
+		assert.Equal(t, 0, instance.GetQueryRetries())
+
+		client, err := instance.GetApiClient()
+		require.NoError(t, err)
+		_, _, err = client.Query(context.Background(), "up", time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"30s"}, received["latency_offset"])
+		assert.Equal(t, "env=prod", received.Get("extra_label"))
+		assert.Equal(t, "1", received.Get("nocache"))
+	})
+}
+
+// newCountingServer returns a server which counts the connections it accepted.
+func newCountingServer(tb testing.TB) (*httptest.Server, *atomic.Int64) {
+	dials := &atomic.Int64{}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status": "success", "data": {"resultType": "vector", "result": []}}`))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			dials.Add(1)
+		}
+	}
+	server.Start()
+	tb.Cleanup(server.Close)
+	return server, dials
+}
+
+func TestInstance_GetApiClient_Cached(t *testing.T) {
+	originalInstances := GetInstances()
+	defer SetInstances(originalInstances)
+	server, dials := newCountingServer(t)
+
+	instance := &Instance{Name: "prometheus", BaseUrl: server.URL}
+	client, err := instance.GetApiClient()
+	require.NoError(t, err)
+
+	t.Run("reuses clients and connections", func(t *testing.T) {
+		for range 10 {
+			cached, err := (&Instance{Name: "prometheus", BaseUrl: server.URL}).GetApiClient()
+			require.NoError(t, err)
+			assert.Same(t, client, cached)
+			_, _, err = cached.Query(context.Background(), "up", time.Now())
+			require.NoError(t, err)
+		}
+		assert.Equal(t, int64(1), dials.Load())
+	})
+
+	t.Run("separates configurations", func(t *testing.T) {
+		other, err := instance.WithTenant("team-a").GetApiClient()
+		require.NoError(t, err)
+		assert.NotSame(t, client, other)
+	})
+
+	t.Run("replaces clients of changed configurations", func(t *testing.T) {
+		changed, err := (&Instance{Name: "prometheus", BaseUrl: server.URL, HeaderKey: "X-Team", HeaderValue: "checkout"}).GetApiClient()
+		require.NoError(t, err)
+		assert.NotSame(t, client, changed)
+		_, ok := apiClients.Load(apiClientKey{instance: "prometheus"})
+		assert.True(t, ok)
+
+		client, err = instance.GetApiClient()
+		require.NoError(t, err)
+		assert.NotSame(t, changed, client)
+	})
+
+	t.Run("evicts clients on instance changes", func(t *testing.T) {
+		SetInstances([]Instance{*instance})
+		reloaded, err := instance.GetApiClient()
+		require.NoError(t, err)
+		assert.NotSame(t, client, reloaded)
+		client = reloaded
+	})
+
+	t.Run("evicts idle clients", func(t *testing.T) {
+		cached, err := instance.getApiClient()
+		require.NoError(t, err)
+		idle, err := instance.WithTenant("team-b").getApiClient()
+		require.NoError(t, err)
+		idle.lastUsed.Store(time.Now().Add(-apiClientIdleTimeout - time.Minute).UnixNano())
+		lastApiClientEviction.Store(0)
+
+		_, err = instance.WithTenant("team-c").getApiClient()
+		require.NoError(t, err)
+		_, ok := apiClients.Load(apiClientKey{instance: "prometheus", tenant: "team-b"})
+		assert.False(t, ok)
+		current, err := instance.getApiClient()
+		require.NoError(t, err)
+		assert.Same(t, cached, current)
+	})
+}
+
+// BenchmarkInstance_Query simulates parallel experiments querying the same instance and reports the dials per query.
+func BenchmarkInstance_Query(b *testing.B) {
+	run := func(b *testing.B, query func(instance *Instance) error) {
+		server, dials := newCountingServer(b)
+		instance := &Instance{Name: "prometheus", BaseUrl: server.URL}
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if err := query(instance); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+		b.ReportMetric(float64(dials.Load())/float64(b.N), "dials/op")
+	}
+
+	b.Run("cached", func(b *testing.B) {
+		run(b, func(instance *Instance) error {
+			client, err := instance.GetApiClient()
+			if err != nil {
+				return err
+			}
+			_, _, err = client.Query(context.Background(), "up", time.Now())
+			return err
+		})
+	})
+	b.Run("uncached", func(b *testing.B) {
+		run(b, func(instance *Instance) error {
+			client, err := instance.newApiClient()
+			if err != nil {
+				return err
+			}
+			defer client.closeIdleConnections()
+			_, _, err = client.api.Query(context.Background(), "up", time.Now())
+			return err
+		})
+	})
+}
@@ -5,12 +5,33 @@ package extinstance
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/api"
 	"github.com/steadybit/discovery-kit/go/discovery_kit_api"
 	"github.com/steadybit/discovery-kit/go/discovery_kit_commons"
 	"github.com/steadybit/discovery-kit/go/discovery_kit_sdk"
 	"github.com/steadybit/extension-kit/extbuild"
 	"github.com/steadybit/extension-prometheus/v2/config"
-	"time"
+)
+
+// Flavours of Prometheus-compatible instances detected by the discovery, see detectFlavour.
+const (
+	FlavourPrometheus      = "Prometheus"
+	FlavourThanos          = "Thanos"
+	FlavourMimir           = "Mimir"
+	FlavourVictoriaMetrics = "VictoriaMetrics"
+	FlavourUnknown         = "unknown"
+
+	// FlavourAttribute is the target attribute of an instance carrying its flavour.
+	FlavourAttribute = "prometheus.instance.flavour"
 )
 
 type instanceDiscovery struct {
@@ -49,6 +70,9 @@ func (d *instanceDiscovery) DescribeTarget() discovery_kit_api.TargetDescription
 			Columns: []discovery_kit_api.Column{
 				{Attribute: "prometheus.instance.name"},
 				{Attribute: "prometheus.instance.url"},
+				{Attribute: "prometheus.instance.reachable"},
+				{Attribute: FlavourAttribute},
+				{Attribute: "prometheus.instance.version"},
 			},
 			OrderBy: []discovery_kit_api.OrderBy{
 				{
@@ -74,24 +98,179 @@ func (d *instanceDiscovery) DescribeAttributes() []discovery_kit_api.AttributeDe
 				One:   "Prometheus instance URL",
 				Other: "Prometheus instance URLs",
 			},
+		}, {
+			Attribute: "prometheus.instance.reachable",
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Prometheus instance reachable",
+				Other: "Prometheus instances reachable",
+			},
+		}, {
+			Attribute: "prometheus.instance.last-error",
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Prometheus instance last error",
+				Other: "Prometheus instance last errors",
+			},
+		}, {
+			Attribute: FlavourAttribute,
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Prometheus instance flavour",
+				Other: "Prometheus instance flavours",
+			},
+		}, {
+			Attribute: "prometheus.instance.version",
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Prometheus instance version",
+				Other: "Prometheus instance versions",
+			},
 		},
 	}
 }
 
-func (d *instanceDiscovery) DiscoverTargets(_ context.Context) ([]discovery_kit_api.Target, error) {
-	targets := make([]discovery_kit_api.Target, len(Instances))
-
-	for i, instance := range Instances {
-		targets[i] = discovery_kit_api.Target{
-			Id:         instance.Name,
-			Label:      instance.Name,
-			TargetType: PrometheusInstanceTargetId,
-			Attributes: map[string][]string{
-				"prometheus.instance.name": {instance.Name},
-				"prometheus.instance.url":  {instance.BaseUrl},
-			},
-		}
+func (d *instanceDiscovery) DiscoverTargets(ctx context.Context) ([]discovery_kit_api.Target, error) {
+	instances := GetInstances()
+	targets := make([]discovery_kit_api.Target, len(instances))
+
+	var wg sync.WaitGroup
+	for i, instance := range instances {
+		wg.Go(func() {
+			status := probeInstance(ctx, &instance)
+			attributes := map[string][]string{
+				"prometheus.instance.name":      {instance.Name},
+				"prometheus.instance.url":       {instance.BaseUrl},
+				"prometheus.instance.reachable": {strconv.FormatBool(status.reachable)},
+			}
+			if status.lastError != "" {
+				attributes["prometheus.instance.last-error"] = []string{status.lastError}
+			}
+			if status.flavour != "" {
+				attributes[FlavourAttribute] = []string{status.flavour}
+			}
+			if status.version != "" {
+				attributes["prometheus.instance.version"] = []string{status.version}
+			}
+			targets[i] = discovery_kit_api.Target{
+				Id:         instance.Name,
+				Label:      instance.Name,
+				TargetType: PrometheusInstanceTargetId,
+				Attributes: attributes,
+			}
+		})
 	}
+	wg.Wait()
 
 	return discovery_kit_commons.ApplyAttributeExcludes(targets, config.Config.DiscoveryAttributesExcludesInstance), nil
 }
+
+type instanceStatus struct {
+	reachable bool
+	lastError string
+	flavour   string
+	version   string
+}
+
+// buildInfo is the result of the buildinfo endpoint, including the application reported by Grafana Mimir.
+type buildInfo struct {
+	Application string `json:"application"`
+	Version     string `json:"version"`
+	Revision    string `json:"revision"`
+	GoVersion   string `json:"goVersion"`
+}
+
+var errReadyNotSupported = errors.New("readiness endpoint not supported")
+
+// probeInstance checks the readiness of an instance and detects its flavour and version. Instances without readiness
+// endpoint, e.g., Mimir behind a path prefix, are reachable if the buildinfo endpoint answers.
+func probeInstance(ctx context.Context, instance *Instance) instanceStatus {
+	client, err := instance.getApiClient()
+	if err != nil {
+		return instanceStatus{lastError: err.Error()}
+	}
+
+	status := instanceStatus{}
+	info, infoErr := fetchBuildInfo(ctx, client.client)
+	if infoErr == nil {
+		status.flavour = detectFlavour(ctx, client.client, info)
+		status.version = info.Version
+	}
+
+	readyErr := checkReady(ctx, client.client)
+	switch {
+	case readyErr == nil:
+		status.reachable = true
+	case errors.Is(readyErr, errReadyNotSupported) && infoErr == nil:
+		status.reachable = true
+	case errors.Is(readyErr, errReadyNotSupported):
+		status.lastError = infoErr.Error()
+	default:
+		status.lastError = readyErr.Error()
+	}
+	return status
+}
+
+func fetchBuildInfo(ctx context.Context, client api.Client) (*buildInfo, error) {
+	resp, body, err := getEndpoint(ctx, client, "/api/v1/status/buildinfo")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("buildinfo endpoint returned %s", resp.Status)
+	}
+	var result struct {
+		Data buildInfo `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("invalid buildinfo response: %w", err)
+	}
+	return &result.Data, nil
+}
+
+func checkReady(ctx context.Context, client api.Client) error {
+	resp, _, err := getEndpoint(ctx, client, "/-/ready")
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return errReadyNotSupported
+	default:
+		return fmt.Errorf("instance not ready: %s", resp.Status)
+	}
+}
+
+func getEndpoint(ctx context.Context, client api.Client, path string) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, client.URL(path, nil).String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client.Do(ctx, req)
+}
+
+// detectFlavour detects the flavour from the buildinfo and the endpoints only one of them serves, as none of them
+// reports it explicitly. The buildinfo only tells which endpoint to look for: Thanos reports its own 0.x version and
+// VictoriaMetrics nothing but the version of the Prometheus API it is compatible with. Instances not confirming a
+// flavour are reported as unknown instead of guessing.
+func detectFlavour(ctx context.Context, client api.Client, info *buildInfo) string {
+	switch {
+	case strings.Contains(strings.ToLower(info.Application), "mimir"):
+		return FlavourMimir
+	case strings.HasPrefix(info.Version, "0."):
+		if hasEndpoint(ctx, client, "/api/v1/stores") {
+			return FlavourThanos
+		}
+	case info.Revision == "" && info.GoVersion == "":
+		if hasEndpoint(ctx, client, "/api/v1/status/active_queries") {
+			return FlavourVictoriaMetrics
+		}
+	default:
+		return FlavourPrometheus
+	}
+	return FlavourUnknown
+}
+
+// hasEndpoint tells whether the instance serves the endpoint.
+func hasEndpoint(ctx context.Context, client api.Client, path string) bool {
+	resp, _, err := getEndpoint(ctx, client, path)
+	return err == nil && resp.StatusCode == http.StatusOK
+}
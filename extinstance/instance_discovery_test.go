@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extinstance
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newProbeServer returns a server answering the readiness endpoint with the given status, the buildinfo endpoint
+// with the given payload, or 404 if empty, and the given flavour-specific endpoints with an empty result.
+func newProbeServer(t *testing.T, readyStatus int, buildInfo string, endpoints ...string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slices.Contains(endpoints, r.URL.Path) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"status": "success", "data": []}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/-/ready":
+			w.WriteHeader(readyStatus)
+		case "/api/v1/status/buildinfo":
+			if buildInfo == "" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(buildInfo))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestInstanceDiscovery_DiscoverTargets(t *testing.T) {
+	prometheusServer := newProbeServer(t, http.StatusOK, `{"status": "success", "data": {"version": "3.5.0", "revision": "abc", "goVersion": "go1.24.4"}}`)
+	starting := newProbeServer(t, http.StatusServiceUnavailable, "")
+
+	originalInstances := GetInstances()
+	defer SetInstances(originalInstances)
+	SetInstances([]Instance{
+		{Name: "prometheus", BaseUrl: prometheusServer.URL},
+		{Name: "starting", BaseUrl: starting.URL},
+		{Name: "unreachable", BaseUrl: "http://127.0.0.1:1"},
+	})
+
+	targets, err := (&instanceDiscovery{}).DiscoverTargets(context.Background())
+	require.NoError(t, err)
+	require.Len(t, targets, 3)
+
+	assert.Equal(t, "prometheus", targets[0].Id)
+	assert.Equal(t, []string{prometheusServer.URL}, targets[0].Attributes["prometheus.instance.url"])
+	assert.Equal(t, []string{"true"}, targets[0].Attributes["prometheus.instance.reachable"])
+	assert.Equal(t, []string{"Prometheus"}, targets[0].Attributes["prometheus.instance.flavour"])
+	assert.Equal(t, []string{"3.5.0"}, targets[0].Attributes["prometheus.instance.version"])
+	assert.NotContains(t, targets[0].Attributes, "prometheus.instance.last-error")
+
+	assert.Equal(t, []string{"false"}, targets[1].Attributes["prometheus.instance.reachable"])
+	assert.Equal(t, []string{"instance not ready: 503 Service Unavailable"}, targets[1].Attributes["prometheus.instance.last-error"])
+	assert.NotContains(t, targets[1].Attributes, "prometheus.instance.version")
+
+	assert.Equal(t, []string{"false"}, targets[2].Attributes["prometheus.instance.reachable"])
+	assert.Contains(t, targets[2].Attributes["prometheus.instance.last-error"][0], "connection refused")
+}
+
+func TestProbeInstance_WithoutReadinessEndpoint(t *testing.T) {
+	mimir := newProbeServer(t, http.StatusNotFound, `{"status": "success", "data": {"application": "Grafana Mimir", "version": "2.16.0", "revision": "abc", "goVersion": "go1.23.8"}}`)
+	status := probeInstance(context.Background(), &Instance{Name: "mimir", BaseUrl: mimir.URL})
+	assert.Equal(t, instanceStatus{reachable: true, flavour: "Mimir", version: "2.16.0"}, status)
+
+	unknown := newProbeServer(t, http.StatusNotFound, "")
+	status = probeInstance(context.Background(), &Instance{Name: "unknown", BaseUrl: unknown.URL})
+	assert.Equal(t, instanceStatus{lastError: "buildinfo endpoint returned 404 Not Found"}, status)
+}
+
+func TestDetectFlavour(t *testing.T) {
+	tests := []struct {
+		name      string
+		buildInfo string
+		endpoints []string
+		want      string
+	}{
+		{
+			name:      "Prometheus",
+			buildInfo: `{"status": "success", "data": {"version": "2.53.0", "revision": "abc", "branch": "HEAD", "goVersion": "go1.22.4"}}`,
+			want:      "Prometheus",
+		},
+		{
+			name:      "Thanos",
+			buildInfo: `{"status": "success", "data": {"version": "0.36.1", "revision": "abc", "branch": "HEAD", "goVersion": "go1.23.1"}}`,
+			endpoints: []string{"/api/v1/stores"},
+			want:      "Thanos",
+		},
+		{
+			name:      "Mimir",
+			buildInfo: `{"status": "success", "data": {"application": "Grafana Mimir", "version": "2.16.0", "revision": "abc", "goVersion": "go1.23.8"}}`,
+			want:      "Mimir",
+		},
+		{
+			name:      "VictoriaMetrics",
+			buildInfo: `{"status": "success", "data": {"version": "2.24.0"}}`,
+			endpoints: []string{"/api/v1/status/active_queries"},
+			want:      "VictoriaMetrics",
+		},
+		{
+			name:      "0.x version without the store API",
+			buildInfo: `{"status": "success", "data": {"version": "0.9.0", "revision": "abc", "goVersion": "go1.23.1"}}`,
+			want:      "unknown",
+		},
+		{
+			name:      "bare version without active queries",
+			buildInfo: `{"status": "success", "data": {"version": "2.24.0"}}`,
+			want:      "unknown",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newProbeServer(t, http.StatusOK, tt.buildInfo, tt.endpoints...)
+			status := probeInstance(context.Background(), &Instance{Name: tt.name, BaseUrl: server.URL})
+			assert.Equal(t, tt.want, status.flavour)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extinstance
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// failoverOrigin is a replica of an instance together with the round tripper sending requests to it.
+type failoverOrigin struct {
+	url *url.URL
+	rt  http.RoundTripper
+}
+
+// failoverRoundTripper sends requests to the replicas of an instance. Replicas are tried in order, starting with the
+// last one which answered successfully. A replica fails if the request errors or returns a server error. With a hedge
+// delay, the next replica is tried in parallel once a replica didn't answer within the delay, and the first successful
+// response wins.
+type failoverRoundTripper struct {
+	// basePath is the path of the base URL, which requests are created for, without trailing slash.
+	basePath   string
+	origins    []failoverOrigin
+	hedgeDelay time.Duration
+	preferred  atomic.Int32
+}
+
+type failoverAttempt struct {
+	index  int
+	resp   *http.Response
+	err    error
+	cancel context.CancelFunc
+}
+
+func (a *failoverAttempt) failed() bool {
+	return a.err != nil || a.resp.StatusCode >= http.StatusInternalServerError
+}
+
+func (a *failoverAttempt) discard() {
+	if a.resp != nil {
+		_ = a.resp.Body.Close()
+	}
+	a.cancel()
+}
+
+func (f *failoverRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.GetBody == nil {
+		// The body can't be sent again, so only the preferred replica is tried.
+		return f.send(req.Context(), req, int(f.preferred.Load()))
+	}
+
+	start := int(f.preferred.Load())
+	attempts := make(chan *failoverAttempt, len(f.origins))
+	cancels := make([]context.CancelFunc, len(f.origins))
+	launched, pending := 0, 0
+	launch := func() {
+		index := (start + launched) % len(f.origins)
+		launched++
+		pending++
+		ctx, cancel := context.WithCancel(req.Context())
+		cancels[index] = cancel
+		go func() {
+			resp, err := f.send(ctx, req, index)
+			attempts <- &failoverAttempt{index: index, resp: resp, err: err, cancel: cancel}
+		}()
+	}
+
+	launch()
+	var last *failoverAttempt
+	for pending > 0 {
+		var hedge <-chan time.Time
+		if f.hedgeDelay > 0 && launched < len(f.origins) {
+			hedge = time.After(f.hedgeDelay)
+		}
+
+		select {
+		case <-hedge:
+			launch()
+		case attempt := <-attempts:
+			pending--
+			if !attempt.failed() {
+				f.preferred.Store(int32(attempt.index))
+				for index, cancel := range cancels {
+					if cancel != nil && index != attempt.index {
+						cancel()
+					}
+				}
+				go discardAttempts(attempts, pending)
+				if last != nil {
+					last.discard()
+				}
+				attempt.resp.Body = &cancelOnClose{ReadCloser: attempt.resp.Body, cancel: attempt.cancel}
+				return attempt.resp, nil
+			}
+
+			log.Debug().Err(attempt.err).Str("origin", f.origins[attempt.index].url.Redacted()).Msg("Prometheus replica failed, trying the next one.")
+			if last != nil {
+				last.discard()
+			}
+			last = attempt
+			if pending == 0 && launched < len(f.origins) {
+				launch()
+			}
+		}
+	}
+
+	// All replicas failed, return the result of the last one.
+	if last.err != nil {
+		last.cancel()
+		return nil, last.err
+	}
+	last.resp.Body = &cancelOnClose{ReadCloser: last.resp.Body, cancel: last.cancel}
+	return last.resp, nil
+}
+
+// send sends the request to the replica with the given index, replacing the base URL by the one of the replica.
+func (f *failoverRoundTripper) send(ctx context.Context, req *http.Request, index int) (*http.Response, error) {
+	origin := f.origins[index].url
+	attempt := req.Clone(ctx)
+	attempt.Host = ""
+	attempt.URL.Scheme = origin.Scheme
+	attempt.URL.Host = origin.Host
+	attempt.URL.Path = strings.TrimSuffix(origin.Path, "/") + strings.TrimPrefix(req.URL.Path, f.basePath)
+	attempt.URL.RawPath = ""
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		attempt.Body = body
+	}
+	return f.origins[index].rt.RoundTrip(attempt)
+}
+
+// discardAttempts closes the responses of attempts which are still pending after another one succeeded.
+func discardAttempts(attempts <-chan *failoverAttempt, pending int) {
+	for range pending {
+		(<-attempts).discard()
+	}
+}
+
+// cancelOnClose releases the context of a request once its response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// getOrigins returns the URLs of all replicas of the instance, starting with the base URL.
+func (i *Instance) getOrigins() ([]*url.URL, error) {
+	origins := make([]*url.URL, 0, len(i.FailoverUrls)+1)
+	for _, origin := range append([]string{i.BaseUrl}, i.FailoverUrls...) {
+		parsed, err := url.Parse(origin)
+		if err != nil {
+			return nil, err
+		}
+		origins = append(origins, parsed)
+	}
+	return origins, nil
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extinstance
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newReplica returns a Prometheus replica answering after the given delay with the given status, counting its requests.
+func newReplica(t *testing.T, status int, delay time.Duration) (*httptest.Server, *atomic.Int64) {
+	requests := &atomic.Int64{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		// The request context is only canceled on disconnects once the body was read
+		_, _ = io.ReadAll(r.Body)
+		assert.Equal(t, "/prometheus/api/v1/query", r.URL.Path)
+		assert.Equal(t, "team-a", r.Header.Get("X-Scope-OrgID"))
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			_, _ = w.Write([]byte(`{"status": "success", "data": {"resultType": "scalar", "result": [1700000000, "1"]}}`))
+		} else {
+			_, _ = w.Write([]byte(`{"status": "error", "errorType": "unavailable", "error": "replica down"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func queryReplicas(t *testing.T, instance *Instance) error {
+	client, err := instance.GetApiClient()
+	require.NoError(t, err)
+	_, _, err = client.Query(context.Background(), "1", time.Now())
+	return err
+}
+
+func TestFailover_UnreachableReplica(t *testing.T) {
+	replica, requests := newReplica(t, http.StatusOK, 0)
+	instance := &Instance{
+		Name:         "ha",
+		BaseUrl:      "http://127.0.0.1:1/prometheus",
+		FailoverUrls: []string{replica.URL + "/prometheus/"},
+		Tenant:       "team-a",
+	}
+
+	require.NoError(t, queryReplicas(t, instance))
+	require.NoError(t, queryReplicas(t, instance))
+	assert.Equal(t, int64(2), requests.Load())
+}
+
+func TestFailover_ServerError(t *testing.T) {
+	failing, failingRequests := newReplica(t, http.StatusServiceUnavailable, 0)
+	healthy, healthyRequests := newReplica(t, http.StatusOK, 0)
+	instance := &Instance{
+		Name:         "ha",
+		BaseUrl:      failing.URL + "/prometheus",
+		FailoverUrls: []string{healthy.URL + "/prometheus"},
+		Tenant:       "team-a",
+	}
+
+	require.NoError(t, queryReplicas(t, instance))
+	// The healthy replica is preferred from now on
+	require.NoError(t, queryReplicas(t, instance))
+	assert.Equal(t, int64(1), failingRequests.Load())
+	assert.Equal(t, int64(2), healthyRequests.Load())
+}
+
+func TestFailover_AllReplicasFail(t *testing.T) {
+	first, _ := newReplica(t, http.StatusServiceUnavailable, 0)
+	second, _ := newReplica(t, http.StatusServiceUnavailable, 0)
+	instance := &Instance{
+		Name:         "ha",
+		BaseUrl:      first.URL + "/prometheus",
+		FailoverUrls: []string{second.URL + "/prometheus"},
+		Tenant:       "team-a",
+	}
+
+	assert.ErrorContains(t, queryReplicas(t, instance), "503")
+}
+
+func TestFailover_HedgesSlowReplica(t *testing.T) {
+	slow, _ := newReplica(t, http.StatusOK, 5*time.Second)
+	fast, fastRequests := newReplica(t, http.StatusOK, 0)
+	instance := &Instance{
+		Name:         "ha",
+		BaseUrl:      slow.URL + "/prometheus",
+		FailoverUrls: []string{fast.URL + "/prometheus"},
+		HedgeDelay:   Duration(50 * time.Millisecond),
+		Tenant:       "team-a",
+	}
+
+	start := time.Now()
+	require.NoError(t, queryReplicas(t, instance))
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Equal(t, int64(1), fastRequests.Load())
+}
+
+func TestGetFailoverOrigins(t *testing.T) {
+	t.Setenv("STEADYBIT_EXTENSION_PROMETHEUS_INSTANCE_7_FAILOVER_ORIGINS", "http://prometheus-1:9090, http://prometheus-2:9090,")
+	assert.Equal(t, []string{"http://prometheus-1:9090", "http://prometheus-2:9090"}, getFailoverOrigins(7))
+	assert.Nil(t, getFailoverOrigins(8))
+}
@@ -0,0 +1,274 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extinstance
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/steadybit/extension-prometheus/v2/config"
+	"golang.org/x/time/rate"
+)
+
+// ErrCircuitOpen is returned for requests to an instance while its circuit breaker is open. Callers shouldn't retry
+// these requests, as they would be rejected again.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// LimitsConfig protects a Prometheus instance from the load of many parallel experiments. Zero values use the global
+// settings.
+type LimitsConfig struct {
+	// MaxConcurrentRequests limits the requests sent to the instance at the same time.
+	MaxConcurrentRequests int `json:"maxConcurrentRequests,omitempty"`
+	// RequestsPerSecond and RequestsBurst configure a token bucket limiting the request rate.
+	RequestsPerSecond float64 `json:"requestsPerSecond,omitempty"`
+	RequestsBurst     int     `json:"requestsBurst,omitempty"`
+	// CircuitBreakerThreshold is the number of consecutive failures after which requests are rejected for
+	// CircuitBreakerOpenDuration.
+	CircuitBreakerThreshold    int      `json:"circuitBreakerThreshold,omitempty"`
+	CircuitBreakerOpenDuration Duration `json:"circuitBreakerOpenDuration,omitempty"`
+}
+
+func (l *LimitsConfig) validate() error {
+	if l.MaxConcurrentRequests < 0 || l.RequestsPerSecond < 0 || l.RequestsBurst < 0 || l.CircuitBreakerThreshold < 0 || l.CircuitBreakerOpenDuration < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	return nil
+}
+
+// getLimits merges the limits of this instance with the global ones.
+func (i *Instance) getLimits() LimitsConfig {
+	limits := LimitsConfig{
+		MaxConcurrentRequests:      config.Config.MaxConcurrentRequests,
+		RequestsPerSecond:          config.Config.RequestsPerSecond,
+		RequestsBurst:              config.Config.RequestsBurst,
+		CircuitBreakerThreshold:    config.Config.CircuitBreakerThreshold,
+		CircuitBreakerOpenDuration: Duration(config.Config.CircuitBreakerOpenDuration),
+	}
+	if i.Limits == nil {
+		return limits
+	}
+	if i.Limits.MaxConcurrentRequests > 0 {
+		limits.MaxConcurrentRequests = i.Limits.MaxConcurrentRequests
+	}
+	if i.Limits.RequestsPerSecond > 0 {
+		limits.RequestsPerSecond = i.Limits.RequestsPerSecond
+	}
+	if i.Limits.RequestsBurst > 0 {
+		limits.RequestsBurst = i.Limits.RequestsBurst
+	}
+	if i.Limits.CircuitBreakerThreshold > 0 {
+		limits.CircuitBreakerThreshold = i.Limits.CircuitBreakerThreshold
+	}
+	if i.Limits.CircuitBreakerOpenDuration > 0 {
+		limits.CircuitBreakerOpenDuration = i.Limits.CircuitBreakerOpenDuration
+	}
+	return limits
+}
+
+// instanceLimiter applies the limits of an instance to all of its API clients, e.g., with different tenants.
+type instanceLimiter struct {
+	instance string
+	// slots is a semaphore of the concurrent requests, nil if unlimited.
+	slots chan struct{}
+	// rate is nil if unlimited.
+	rate *rate.Limiter
+	// breaker is nil if disabled.
+	breaker *circuitBreaker
+}
+
+// instanceLimiters are shared across API clients, so limits and breaker state survive evicted clients.
+var instanceLimiters sync.Map
+
+func getInstanceLimiter(i *Instance) *instanceLimiter {
+	limits := i.getLimits()
+	key := fmt.Sprintf("%s\x00%s\x00%v", i.Name, i.BaseUrl, limits)
+	if limiter, ok := instanceLimiters.Load(key); ok {
+		return limiter.(*instanceLimiter)
+	}
+	limiter, _ := instanceLimiters.LoadOrStore(key, newInstanceLimiter(i.Name, limits))
+	return limiter.(*instanceLimiter)
+}
+
+func newInstanceLimiter(instance string, limits LimitsConfig) *instanceLimiter {
+	limiter := &instanceLimiter{instance: instance}
+	if limits.MaxConcurrentRequests > 0 {
+		limiter.slots = make(chan struct{}, limits.MaxConcurrentRequests)
+	}
+	if limits.RequestsPerSecond > 0 {
+		burst := limits.RequestsBurst
+		if burst == 0 {
+			burst = int(math.Ceil(limits.RequestsPerSecond))
+		}
+		limiter.rate = rate.NewLimiter(rate.Limit(limits.RequestsPerSecond), burst)
+	}
+	if limits.CircuitBreakerThreshold > 0 {
+		limiter.breaker = &circuitBreaker{
+			instance:     instance,
+			threshold:    limits.CircuitBreakerThreshold,
+			openDuration: time.Duration(limits.CircuitBreakerOpenDuration),
+		}
+		circuitBreakerState.WithLabelValues(instance).Set(float64(circuitClosed))
+	}
+	return limiter
+}
+
+// limitRoundTripper rejects requests while the circuit breaker is open and waits for the rate and concurrency limits
+// before sending them.
+type limitRoundTripper struct {
+	limiter *instanceLimiter
+	rt      http.RoundTripper
+}
+
+func (l *limitRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	limiter := l.limiter
+	if limiter.breaker != nil {
+		if err := limiter.breaker.allow(); err != nil {
+			rejectedRequests.WithLabelValues(limiter.instance, "circuit_open").Inc()
+			return nil, err
+		}
+	}
+
+	resp, err := l.send(req)
+
+	if limiter.breaker != nil {
+		switch {
+		case req.Context().Err() != nil:
+			// Requests canceled by the caller don't tell anything about the health of the instance.
+			limiter.breaker.release()
+		case err != nil:
+			limiter.breaker.record(err)
+		case isUnavailable(resp.StatusCode):
+			limiter.breaker.record(fmt.Errorf("server responded with %s", resp.Status))
+		default:
+			limiter.breaker.record(nil)
+		}
+	}
+	return resp, err
+}
+
+// isUnavailable tells whether a response status means that the instance is down or overloaded. Other errors, e.g., a
+// 422 or 503 of Prometheus for a query which failed or timed out, only concern a single query and don't count toward
+// the circuit breaker.
+func isUnavailable(statusCode int) bool {
+	return statusCode == http.StatusBadGateway || statusCode == http.StatusGatewayTimeout || statusCode == http.StatusTooManyRequests
+}
+
+func (l *limitRoundTripper) send(req *http.Request) (*http.Response, error) {
+	limiter := l.limiter
+	if limiter.rate != nil {
+		if err := limiter.rate.Wait(req.Context()); err != nil {
+			rejectedRequests.WithLabelValues(limiter.instance, "rate_limited").Inc()
+			return nil, fmt.Errorf("rate limit of Prometheus instance '%s' exceeded: %w", limiter.instance, err)
+		}
+	}
+	if limiter.slots != nil {
+		select {
+		case limiter.slots <- struct{}{}:
+			// The slot is released once the response headers are received, as the instance is done processing the
+			// request by then.
+			defer func() { <-limiter.slots }()
+		case <-req.Context().Done():
+			rejectedRequests.WithLabelValues(limiter.instance, "concurrency_limited").Inc()
+			return nil, fmt.Errorf("concurrency limit of Prometheus instance '%s' exceeded: %w", limiter.instance, req.Context().Err())
+		}
+	}
+	return l.rt.RoundTrip(req)
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker opens after a number of consecutive failures and rejects all requests until the open duration has
+// passed. Then, a single trial request is let through, which either closes the breaker again or keeps it open.
+type circuitBreaker struct {
+	instance     string
+	threshold    int
+	openDuration time.Duration
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	lastErr  error
+	openedAt time.Time
+	trial    bool
+}
+
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == circuitOpen && time.Since(b.openedAt) >= b.openDuration {
+		b.setState(circuitHalfOpen)
+	}
+	switch {
+	case b.state == circuitOpen:
+		return fmt.Errorf("%w: Prometheus instance '%s' failed %d times in a row, retrying in %s. Last error: %v", ErrCircuitOpen, b.instance, b.failures, (b.openDuration - time.Since(b.openedAt)).Round(time.Second), b.lastErr)
+	case b.state == circuitHalfOpen && b.trial:
+		return fmt.Errorf("%w: Prometheus instance '%s' failed %d times in a row, waiting for a trial request. Last error: %v", ErrCircuitOpen, b.instance, b.failures, b.lastErr)
+	case b.state == circuitHalfOpen:
+		b.trial = true
+	}
+	return nil
+}
+
+// record records the result of a request, err is nil for successful ones.
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	if err == nil {
+		b.failures = 0
+		b.lastErr = nil
+		if b.state != circuitClosed {
+			b.setState(circuitClosed)
+		}
+		return
+	}
+
+	b.failures++
+	b.lastErr = err
+	if b.state == circuitHalfOpen || (b.state == circuitClosed && b.failures >= b.threshold) {
+		b.openedAt = time.Now()
+		b.setState(circuitOpen)
+	}
+}
+
+// release ends a trial request without result.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+func (b *circuitBreaker) setState(state circuitState) {
+	event := log.Info()
+	if state == circuitOpen {
+		event = log.Warn().Err(b.lastErr).Int("failures", b.failures).Dur("openDuration", b.openDuration)
+	}
+	event.Str("instance", b.instance).Str("from", b.state.String()).Str("to", state.String()).Msg("Circuit breaker of Prometheus instance changed state.")
+	b.state = state
+	circuitBreakerState.WithLabelValues(b.instance).Set(float64(state))
+}
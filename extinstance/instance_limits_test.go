@@ -0,0 +1,175 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extinstance
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFlakyServer returns a server which fails while failing is set.
+func newFlakyServer(t *testing.T, failing *atomic.Bool) (*httptest.Server, *atomic.Int64) {
+	requests := &atomic.Int64{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status": "success", "data": {"resultType": "scalar", "result": [1700000000, "1"]}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func queryWithLimits(instance *Instance) error {
+	client, err := instance.GetApiClient()
+	if err != nil {
+		return err
+	}
+	_, _, err = client.Query(context.Background(), "1", time.Now())
+	return err
+}
+
+func TestLimitsConfig_Validate(t *testing.T) {
+	assert.NoError(t, (&LimitsConfig{MaxConcurrentRequests: 4, RequestsPerSecond: 0.5}).validate())
+	assert.ErrorContains(t, (&LimitsConfig{RequestsBurst: -1}).validate(), "must not be negative")
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	failing := &atomic.Bool{}
+	failing.Store(true)
+	server, requests := newFlakyServer(t, failing)
+	instance := &Instance{
+		Name:    "flaky",
+		BaseUrl: server.URL,
+		Limits:  &LimitsConfig{CircuitBreakerThreshold: 3, CircuitBreakerOpenDuration: Duration(100 * time.Millisecond)},
+	}
+
+	for range 3 {
+		err := queryWithLimits(instance)
+		require.Error(t, err)
+		assert.False(t, errors.Is(err, ErrCircuitOpen))
+	}
+	assert.Equal(t, float64(circuitOpen), testutil.ToFloat64(circuitBreakerState.WithLabelValues("flaky")))
+
+	// Requests are rejected without reaching the instance
+	err := queryWithLimits(instance)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.ErrorContains(t, err, "Prometheus instance 'flaky' failed 3 times in a row")
+	assert.Equal(t, int64(3), requests.Load())
+	assert.Equal(t, float64(1), testutil.ToFloat64(rejectedRequests.WithLabelValues("flaky", "circuit_open")))
+
+	// A failing trial request opens the breaker again
+	time.Sleep(100 * time.Millisecond)
+	assert.Error(t, queryWithLimits(instance))
+	assert.ErrorIs(t, queryWithLimits(instance), ErrCircuitOpen)
+	assert.Equal(t, int64(4), requests.Load())
+
+	// A successful trial request closes the breaker
+	failing.Store(false)
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, queryWithLimits(instance))
+	assert.NoError(t, queryWithLimits(instance))
+	assert.Equal(t, float64(circuitClosed), testutil.ToFloat64(circuitBreakerState.WithLabelValues("flaky")))
+}
+
+func TestCircuitBreaker_IgnoresQueryErrors(t *testing.T) {
+	responses := map[int]string{
+		http.StatusUnprocessableEntity: `{"status": "error", "errorType": "execution", "error": "query processing would load too many samples"}`,
+		http.StatusServiceUnavailable:  `{"status": "error", "errorType": "timeout", "error": "query timed out in expression evaluation"}`,
+		http.StatusInternalServerError: `{"status": "error", "errorType": "internal", "error": "unexpected error"}`,
+	}
+	for status, body := range responses {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(status)
+				_, _ = w.Write([]byte(body))
+			}))
+			t.Cleanup(server.Close)
+			instance := &Instance{
+				Name:    "query-errors",
+				BaseUrl: server.URL,
+				Limits:  &LimitsConfig{CircuitBreakerThreshold: 1, CircuitBreakerOpenDuration: Duration(time.Minute)},
+			}
+
+			for range 3 {
+				err := queryWithLimits(instance)
+				require.Error(t, err)
+				assert.False(t, errors.Is(err, ErrCircuitOpen))
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker_IgnoresCanceledRequests(t *testing.T) {
+	breaker := &circuitBreaker{instance: "test", threshold: 1, openDuration: time.Minute}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	rt := &limitRoundTripper{limiter: &instanceLimiter{instance: "test", breaker: breaker}, rt: http.DefaultTransport}
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err = rt.RoundTrip(req)
+	assert.Error(t, err)
+	assert.NoError(t, breaker.allow())
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := inFlight.Add(1)
+		for previous := maxInFlight.Load(); current > previous && !maxInFlight.CompareAndSwap(previous, current); previous = maxInFlight.Load() {
+		}
+		time.Sleep(20 * time.Millisecond)
+		inFlight.Add(-1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status": "success", "data": {"resultType": "scalar", "result": [1700000000, "1"]}}`))
+	}))
+	defer server.Close()
+
+	instance := &Instance{Name: "limited", BaseUrl: server.URL, Limits: &LimitsConfig{MaxConcurrentRequests: 2}}
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			assert.NoError(t, queryWithLimits(instance))
+		})
+	}
+	wg.Wait()
+	assert.Equal(t, int64(2), maxInFlight.Load())
+}
+
+func TestRateLimit(t *testing.T) {
+	failing := &atomic.Bool{}
+	server, requests := newFlakyServer(t, failing)
+	instance := &Instance{Name: "rate-limited", BaseUrl: server.URL, Limits: &LimitsConfig{RequestsPerSecond: 1, RequestsBurst: 2}}
+
+	require.NoError(t, queryWithLimits(instance))
+	require.NoError(t, queryWithLimits(instance))
+
+	// The bucket is empty, so the next request would have to wait longer than the deadline
+	client, err := instance.GetApiClient()
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, _, err = client.Query(ctx, "1", time.Now())
+	assert.ErrorContains(t, err, "rate limit of Prometheus instance 'rate-limited' exceeded")
+	assert.Equal(t, int64(2), requests.Load())
+}
@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extinstance
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	retry "github.com/sethvargo/go-retry"
+	"github.com/steadybit/extension-prometheus/v2/exttracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const metricsNamespace = "steadybit_extension_prometheus"
+
+var (
+	circuitBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "circuit_breaker_state",
+		Help:      "State of the circuit breaker of a Prometheus instance: 0 closed, 1 open, 2 half-open.",
+	}, []string{"instance"})
+	rejectedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "rejected_requests_total",
+		Help:      "Requests to a Prometheus instance rejected by its circuit breaker, rate or concurrency limit.",
+	}, []string{"instance", "reason"})
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "requests_total",
+		Help:      "HTTP requests sent to a Prometheus instance by endpoint and status code, or error if no response was received.",
+	}, []string{"instance", "endpoint", "code"})
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "request_duration_seconds",
+		Help:      "Duration of HTTP requests sent to a Prometheus instance until the response headers were received.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"instance", "endpoint"})
+	queryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "query_errors_total",
+		Help:      "Queries against a Prometheus instance which failed after all retries, by error type.",
+	}, []string{"instance", "type"})
+	queryRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "query_retries_total",
+		Help:      "Retries of failed queries against a Prometheus instance.",
+	}, []string{"instance"})
+	queryResultSeries = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "query_result_series",
+		Help:      "Series returned by a query against a Prometheus instance. Queries without any series are counted in the 0 bucket.",
+		Buckets:   []float64{0, 1, 5, 10, 50, 100, 500, 1000},
+	}, []string{"instance"})
+	queryResultSamples = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "query_result_samples_total",
+		Help:      "Samples returned by queries against a Prometheus instance.",
+	}, []string{"instance"})
+)
+
+// Retry calls fn until it succeeds, retrying at most the configured query retries of the instance with a Fibonacci
+// backoff. Requests rejected by the circuit breaker aren't retried. Retries and final errors are recorded in the
+// extension's metrics, and each attempt is traced in its own span.
+func (i *Instance) Retry(ctx context.Context, fn func(ctx context.Context) error) error {
+	attempts := 0
+	err := retry.Do(ctx, retry.WithMaxRetries(uint64(i.GetQueryRetries()), retry.NewFibonacci(50*time.Millisecond)), func(ctx context.Context) error {
+		if attempts > 0 {
+			queryRetries.WithLabelValues(i.Name).Inc()
+		}
+		attempts++
+		ctx, span := exttracing.Tracer().Start(ctx, "prometheus.attempt", trace.WithAttributes(
+			exttracing.InstanceKey.String(i.Name),
+			attribute.Int("retry.attempt", attempts),
+		))
+		err := fn(ctx)
+		exttracing.End(span, err)
+		if err == nil || errors.Is(err, ErrCircuitOpen) {
+			return err
+		}
+		return retry.RetryableError(err)
+	})
+	if err != nil {
+		queryErrors.WithLabelValues(i.Name, errorType(err)).Inc()
+	}
+	return err
+}
+
+// RecordQueryResult records the series and samples returned by a query in the extension's metrics.
+func (i *Instance) RecordQueryResult(value model.Value) {
+	series, samples := 0, 0
+	switch v := value.(type) {
+	case model.Vector:
+		series, samples = len(v), len(v)
+	case model.Matrix:
+		series = len(v)
+		for _, stream := range v {
+			samples += len(stream.Values) + len(stream.Histograms)
+		}
+	case *model.Scalar, *model.String:
+		series, samples = 1, 1
+	}
+	queryResultSeries.WithLabelValues(i.Name).Observe(float64(series))
+	queryResultSamples.WithLabelValues(i.Name).Add(float64(samples))
+}
+
+func errorType(err error) string {
+	var apiErr *v1.Error
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &apiErr):
+		return string(apiErr.Type)
+	default:
+		return "network"
+	}
+}
+
+// metricsRoundTripper records the count and duration of the requests sent to an instance.
+type metricsRoundTripper struct {
+	instance string
+	// basePath is the path of the base URL, which is removed from the endpoint label.
+	basePath string
+	rt       http.RoundTripper
+}
+
+func (m *metricsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := strings.TrimPrefix(req.URL.Path, m.basePath)
+	start := time.Now()
+	resp, err := m.rt.RoundTrip(req)
+	requestDuration.WithLabelValues(m.instance, endpoint).Observe(time.Since(start).Seconds())
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	requests.WithLabelValues(m.instance, endpoint, code).Inc()
+	return resp, err
+}
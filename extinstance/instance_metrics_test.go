@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extinstance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstance_Retry(t *testing.T) {
+	instance := &Instance{Name: "retrying", QueryRetries: new(2)}
+
+	calls := 0
+	err := instance.Retry(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection reset")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, float64(2), testutil.ToFloat64(queryRetries.WithLabelValues("retrying")))
+
+	err = instance.Retry(context.Background(), func(ctx context.Context) error {
+		return &v1.Error{Type: v1.ErrBadData, Msg: "parse error"}
+	})
+	assert.ErrorContains(t, err, "parse error")
+	assert.Equal(t, float64(1), testutil.ToFloat64(queryErrors.WithLabelValues("retrying", "bad_data")))
+
+	calls = 0
+	err = instance.Retry(context.Background(), func(ctx context.Context) error {
+		calls++
+		return fmt.Errorf("request failed: %w", ErrCircuitOpen)
+	})
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, float64(1), testutil.ToFloat64(queryErrors.WithLabelValues("retrying", "circuit_open")))
+}
+
+func TestErrorType(t *testing.T) {
+	assert.Equal(t, "timeout", errorType(fmt.Errorf("query: %w", context.DeadlineExceeded)))
+	assert.Equal(t, "canceled", errorType(context.Canceled))
+	assert.Equal(t, "execution", errorType(&v1.Error{Type: v1.ErrExec}))
+	assert.Equal(t, "network", errorType(errors.New("connection refused")))
+}
+
+func TestInstance_RecordQueryResult(t *testing.T) {
+	instance := &Instance{Name: "recording"}
+	instance.RecordQueryResult(model.Matrix{
+		{Metric: model.Metric{"job": "a"}, Values: []model.SamplePair{{Value: 1}, {Value: 2}}},
+		{Metric: model.Metric{"job": "b"}, Values: []model.SamplePair{{Value: 3}}},
+	})
+	instance.RecordQueryResult(model.Vector{})
+
+	assert.Equal(t, float64(3), testutil.ToFloat64(queryResultSamples.WithLabelValues("recording")))
+	// The empty result is counted in the 0 bucket
+	expected := `
+# HELP steadybit_extension_prometheus_query_result_series Series returned by a query against a Prometheus instance. Queries without any series are counted in the 0 bucket.
+# TYPE steadybit_extension_prometheus_query_result_series histogram
+steadybit_extension_prometheus_query_result_series_bucket{instance="recording",le="0"} 1
+steadybit_extension_prometheus_query_result_series_bucket{instance="recording",le="1"} 1
+steadybit_extension_prometheus_query_result_series_bucket{instance="recording",le="5"} 2
+steadybit_extension_prometheus_query_result_series_bucket{instance="recording",le="10"} 2
+steadybit_extension_prometheus_query_result_series_bucket{instance="recording",le="50"} 2
+steadybit_extension_prometheus_query_result_series_bucket{instance="recording",le="100"} 2
+steadybit_extension_prometheus_query_result_series_bucket{instance="recording",le="500"} 2
+steadybit_extension_prometheus_query_result_series_bucket{instance="recording",le="1000"} 2
+steadybit_extension_prometheus_query_result_series_bucket{instance="recording",le="+Inf"} 2
+steadybit_extension_prometheus_query_result_series_sum{instance="recording"} 2
+steadybit_extension_prometheus_query_result_series_count{instance="recording"} 2
+`
+	histogram := queryResultSeries.WithLabelValues("recording").(prometheus.Collector)
+	assert.NoError(t, testutil.CollectAndCompare(histogram, strings.NewReader(expected)))
+}
+
+func TestInstance_GetApiClient_RecordsRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("query") == "invalid" || r.FormValue("query") == "invalid" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"status": "error", "errorType": "bad_data", "error": "parse error"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status": "success", "data": {"resultType": "vector", "result": []}}`))
+	}))
+	defer server.Close()
+
+	client, err := (&Instance{Name: "instrumented", BaseUrl: server.URL + "/prometheus"}).GetApiClient()
+	require.NoError(t, err)
+	_, _, err = client.Query(context.Background(), "up", time.Now())
+	require.NoError(t, err)
+	_, _, err = client.Query(context.Background(), "invalid", time.Now())
+	require.Error(t, err)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(requests.WithLabelValues("instrumented", "/api/v1/query", "200")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(requests.WithLabelValues("instrumented", "/api/v1/query", "400")))
+}
@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extinstance
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// defaultSigV4Service is the signing name of Amazon Managed Service for Prometheus.
+const defaultSigV4Service = "aps"
+
+// SigV4Config configures AWS Signature Version 4 signing, e.g., for Amazon Managed Service for Prometheus.
+type SigV4Config struct {
+	Region string `json:"region"`
+	// Service is the signing name of the AWS service, defaults to aps.
+	Service string `json:"service,omitempty"`
+	// AccessKeyId, SecretAccessKey and SessionToken are static credentials. Without them, the credentials are
+	// resolved by the default AWS credential chain, i.e., from AWS_* environment variables, shared credential files,
+	// web identity tokens (IRSA) or the instance metadata service.
+	AccessKeyId     string `json:"accessKeyId,omitempty"`
+	SecretAccessKey string `json:"secretAccessKey,omitempty"`
+	SessionToken    string `json:"sessionToken,omitempty"`
+	// Profile selects a profile of the shared AWS config and credential files.
+	Profile string `json:"profile,omitempty"`
+}
+
+func (s *SigV4Config) validate() error {
+	if s.Region == "" {
+		return fmt.Errorf("sigv4 requires a region")
+	}
+	if (s.AccessKeyId == "") != (s.SecretAccessKey == "") {
+		return fmt.Errorf("sigv4 requires both accessKeyId and secretAccessKey for static credentials")
+	}
+	return nil
+}
+
+func (s *SigV4Config) service() string {
+	if s.Service == "" {
+		return defaultSigV4Service
+	}
+	return s.Service
+}
+
+// sigV4CredentialProviders are shared across API clients, so resolved credentials are cached until they expire.
+var sigV4CredentialProviders sync.Map
+
+func getSigV4CredentialsProvider(ctx context.Context, s *SigV4Config) (aws.CredentialsProvider, error) {
+	if s.AccessKeyId != "" {
+		return credentials.NewStaticCredentialsProvider(s.AccessKeyId, s.SecretAccessKey, s.SessionToken), nil
+	}
+	key := s.Region + "\x00" + s.Profile
+	if provider, ok := sigV4CredentialProviders.Load(key); ok {
+		return provider.(aws.CredentialsProvider), nil
+	}
+
+	options := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(s.Region)}
+	if s.Profile != "" {
+		options = append(options, awsconfig.WithSharedConfigProfile(s.Profile))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	provider, _ := sigV4CredentialProviders.LoadOrStore(key, cfg.Credentials)
+	return provider.(aws.CredentialsProvider), nil
+}
+
+// sigV4RoundTripper signs each request with AWS Signature Version 4. It must be the last round tripper to modify the
+// request, as later changes would invalidate the signature.
+type sigV4RoundTripper struct {
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	region      string
+	service     string
+	rt          http.RoundTripper
+}
+
+func newSigV4RoundTripper(s *SigV4Config, rt http.RoundTripper) (*sigV4RoundTripper, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	provider, err := getSigV4CredentialsProvider(context.Background(), s)
+	if err != nil {
+		return nil, err
+	}
+	return &sigV4RoundTripper{
+		credentials: provider,
+		signer:      v4.NewSigner(),
+		region:      s.Region,
+		service:     s.service(),
+		rt:          rt,
+	}, nil
+}
+
+func (s *sigV4RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+
+	payload := []byte{}
+	if req.Body != nil {
+		var err error
+		payload, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(payload))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(payload)), nil
+		}
+	}
+	payloadHash := sha256.Sum256(payload)
+
+	creds, err := s.credentials.Retrieve(req.Context())
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+
+	// Headers without values, e.g., the Idempotency-Key set by the Prometheus client, are not sent by the transport
+	// and must not be signed either.
+	unsent := http.Header{}
+	for key, values := range req.Header {
+		if values == nil {
+			unsent[key] = values
+			delete(req.Header, key)
+		}
+	}
+	if err := s.signer.SignHTTP(req.Context(), creds, req, hex.EncodeToString(payloadHash[:]), s.service, s.region, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to sign request: %w", err)
+	}
+	maps.Copy(req.Header, unsent)
+	return s.rt.RoundTrip(req)
+}
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extinstance
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSigV4Server returns a server which only answers requests carrying a valid signature for the given credentials.
+// The signature is verified by signing a copy of the request with the signed headers of the original one.
+func newSigV4Server(t *testing.T, creds aws.Credentials, region string, service string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 ") {
+			http.Error(w, "missing signature", http.StatusForbidden)
+			return
+		}
+		assert.Contains(t, authorization, "Credential="+creds.AccessKeyID+"/")
+		assert.Contains(t, authorization, "/"+region+"/"+service+"/aws4_request")
+		assert.Equal(t, creds.SessionToken, r.Header.Get("X-Amz-Security-Token"))
+
+		signingTime, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+		require.NoError(t, err)
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		expected, err := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), bytes.NewReader(body))
+		require.NoError(t, err)
+		signedHeaders := authorization[strings.Index(authorization, "SignedHeaders=")+len("SignedHeaders="):]
+		signedHeaders = signedHeaders[:strings.Index(signedHeaders, ",")]
+		for _, header := range strings.Split(signedHeaders, ";") {
+			if header != "host" {
+				expected.Header[http.CanonicalHeaderKey(header)] = r.Header.Values(header)
+			}
+		}
+		expected.Header.Del("Authorization")
+		payloadHash := sha256.Sum256(body)
+		require.NoError(t, v4.NewSigner().SignHTTP(context.Background(), creds, expected, hex.EncodeToString(payloadHash[:]), service, region, signingTime))
+
+		if expected.Header.Get("Authorization") != authorization {
+			http.Error(w, "signature mismatch", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/series") {
+			_, _ = w.Write([]byte(`{"status": "success", "data": []}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status": "success", "data": {"resultType": "vector", "result": []}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSigV4Config_Validate(t *testing.T) {
+	assert.NoError(t, (&SigV4Config{Region: "eu-central-1"}).validate())
+	assert.NoError(t, (&SigV4Config{Region: "eu-central-1", AccessKeyId: "AKID", SecretAccessKey: "secret"}).validate())
+	assert.ErrorContains(t, (&SigV4Config{}).validate(), "requires a region")
+	assert.ErrorContains(t, (&SigV4Config{Region: "eu-central-1", AccessKeyId: "AKID"}).validate(), "requires both accessKeyId and secretAccessKey")
+}
+
+func TestInstance_GetApiClient_WithSigV4(t *testing.T) {
+	creds := aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", SessionToken: "session"}
+	server := newSigV4Server(t, creds, "eu-central-1", defaultSigV4Service)
+
+	instance := &Instance{
+		Name:        "amp",
+		BaseUrl:     server.URL + "/workspaces/ws-123",
+		HeaderKey:   "X-Scope",
+		HeaderValue: "signed-as-well",
+		SigV4: &SigV4Config{
+			Region:          "eu-central-1",
+			AccessKeyId:     creds.AccessKeyID,
+			SecretAccessKey: creds.SecretAccessKey,
+			SessionToken:    creds.SessionToken,
+		},
+	}
+	client, err := instance.GetApiClient()
+	require.NoError(t, err)
+
+	// Queries are sent as POST with a form body, label matchers need to be escaped in the canonical request
+	_, _, err = client.Query(context.Background(), `up{job="prometheus"}`, time.Now())
+	assert.NoError(t, err)
+	_, _, err = client.Series(context.Background(), []string{`up{job=~"node.*"}`}, time.Now().Add(-time.Hour), time.Now())
+	assert.NoError(t, err)
+
+	instance.SigV4.SecretAccessKey = "wrong"
+	client, err = instance.GetApiClient()
+	require.NoError(t, err)
+	_, _, err = client.Query(context.Background(), "up", time.Now())
+	assert.ErrorContains(t, err, "403")
+}
+
+func TestInstance_GetApiClient_WithSigV4FromEnvironment(t *testing.T) {
+	creds := aws.Credentials{AccessKeyID: "AKIDFROMENV", SecretAccessKey: "secret-from-env"}
+	server := newSigV4Server(t, creds, "us-east-1", "custom")
+	t.Setenv("AWS_ACCESS_KEY_ID", creds.AccessKeyID)
+	t.Setenv("AWS_SECRET_ACCESS_KEY", creds.SecretAccessKey)
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+
+	instance := &Instance{Name: "amp", BaseUrl: server.URL, SigV4: &SigV4Config{Region: "us-east-1", Service: "custom"}}
+	client, err := instance.GetApiClient()
+	require.NoError(t, err)
+	_, _, err = client.Query(context.Background(), "up", time.Now())
+	assert.NoError(t, err)
+}
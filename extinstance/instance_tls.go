@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extinstance
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSConfig configures the TLS client of a Prometheus instance. Certificate files are reloaded when they change.
+type TLSConfig struct {
+	// CaFile is a PEM bundle of CAs used to verify the server certificate instead of the system roots.
+	CaFile string `json:"caFile,omitempty"`
+	// CertFile and KeyFile are the PEM encoded client certificate and key for mutual TLS.
+	CertFile   string `json:"certFile,omitempty"`
+	KeyFile    string `json:"keyFile,omitempty"`
+	ServerName string `json:"serverName,omitempty"`
+	// MinVersion is the minimum TLS version, one of 1.0, 1.1, 1.2 or 1.3.
+	MinVersion         string `json:"minVersion,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+}
+
+func (t *TLSConfig) validate() error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("certFile and keyFile must be configured together")
+	}
+	if _, ok := tlsVersions[t.MinVersion]; t.MinVersion != "" && !ok {
+		return fmt.Errorf("unsupported minVersion '%s'", t.MinVersion)
+	}
+	return nil
+}
+
+// newTLSClientConfig creates the tls.Config of an instance reachable at the given host. Without instance specific TLS
+// config, only the global insecureSkipVerify setting applies.
+func newTLSClientConfig(t *TLSConfig, host string, insecureSkipVerify bool) (*tls.Config, error) {
+	if t == nil {
+		return &tls.Config{InsecureSkipVerify: insecureSkipVerify}, nil
+	}
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+
+	files := getTLSFiles(t)
+	if _, _, err := files.load(); err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         t.ServerName,
+		MinVersion:         tlsVersions[t.MinVersion],
+		InsecureSkipVerify: insecureSkipVerify || t.InsecureSkipVerify,
+	}
+	if t.CertFile != "" {
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			_, certificate, err := files.load()
+			return certificate, err
+		}
+	}
+	if t.CaFile != "" && !tlsConfig.InsecureSkipVerify {
+		// The standard verification only supports a fixed RootCAs pool. To pick up changes of the CA file, the
+		// verification is done with the current pool in VerifyConnection instead.
+		serverName := t.ServerName
+		if serverName == "" {
+			serverName = host
+		}
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			rootCAs, _, err := files.load()
+			if err != nil {
+				return err
+			}
+			return verifyServerCertificate(state, rootCAs, serverName)
+		}
+	}
+	return tlsConfig, nil
+}
+
+func verifyServerCertificate(state tls.ConnectionState, rootCAs *x509.CertPool, serverName string) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("server presented no certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, certificate := range state.PeerCertificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+	_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         rootCAs,
+		Intermediates: intermediates,
+		DNSName:       serverName,
+	})
+	return err
+}
+
+// tlsFiles holds the parsed content of the TLS files of an instance and reloads them once one of them changes.
+type tlsFiles struct {
+	caFile   string
+	certFile string
+	keyFile  string
+
+	mu          sync.Mutex
+	modTimes    []time.Time
+	rootCAs     *x509.CertPool
+	certificate *tls.Certificate
+}
+
+// allTLSFiles are shared across API clients, so the files are only parsed again when they actually changed.
+var allTLSFiles sync.Map
+
+func getTLSFiles(t *TLSConfig) *tlsFiles {
+	key := strings.Join([]string{t.CaFile, t.CertFile, t.KeyFile}, "\x00")
+	files, _ := allTLSFiles.LoadOrStore(key, &tlsFiles{caFile: t.CaFile, certFile: t.CertFile, keyFile: t.KeyFile})
+	return files.(*tlsFiles)
+}
+
+// load returns the current CA pool and client certificate. If changed files can't be loaded, e.g., while only one of
+// certificate and key has been rotated yet, the previously loaded content is kept.
+func (f *tlsFiles) load() (*x509.CertPool, *tls.Certificate, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	modTimes, err := f.currentModTimes()
+	if err == nil && f.modTimes != nil && slices.EqualFunc(modTimes, f.modTimes, time.Time.Equal) {
+		return f.rootCAs, f.certificate, nil
+	}
+	if err == nil {
+		err = f.reload(modTimes)
+	}
+	if err != nil {
+		if f.modTimes == nil {
+			return nil, nil, err
+		}
+		log.Warn().Err(err).Msg("Failed to reload TLS files, keeping the previously loaded ones.")
+	}
+	return f.rootCAs, f.certificate, nil
+}
+
+func (f *tlsFiles) reload(modTimes []time.Time) error {
+	var rootCAs *x509.CertPool
+	if f.caFile != "" {
+		pem, err := os.ReadFile(f.caFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA file: %w", err)
+		}
+		rootCAs = x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("CA file '%s' contains no valid certificates", f.caFile)
+		}
+	}
+
+	var certificate *tls.Certificate
+	if f.certFile != "" {
+		loaded, err := tls.LoadX509KeyPair(f.certFile, f.keyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		certificate = &loaded
+	}
+
+	f.rootCAs = rootCAs
+	f.certificate = certificate
+	f.modTimes = modTimes
+	return nil
+}
+
+func (f *tlsFiles) currentModTimes() ([]time.Time, error) {
+	modTimes := []time.Time{}
+	for _, path := range []string{f.caFile, f.certFile, f.keyFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+	return modTimes, nil
+}
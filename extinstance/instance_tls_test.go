@@ -0,0 +1,196 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extinstance
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	tls         tls.Certificate
+}
+
+func newTestCertificate(t *testing.T, commonName string, parent *testCertificate, template x509.Certificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.Subject = pkix.Name{CommonName: commonName}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	signer, signerKey := &template, key
+	if parent != nil {
+		signer, signerKey = parent.certificate, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCertificate{
+		certificate: certificate,
+		key:         key,
+		tls:         tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key},
+	}
+}
+
+func newTestCA(t *testing.T) *testCertificate {
+	return newTestCertificate(t, "test-ca", nil, x509.Certificate{
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+}
+
+func writeCertificate(t *testing.T, path string, certificate *testCertificate) {
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.certificate.Raw}), 0o600))
+}
+
+func writeKey(t *testing.T, path string, certificate *testCertificate) {
+	der, err := x509.MarshalECPrivateKey(certificate.key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600))
+}
+
+func newTLSServer(t *testing.T, ca *testCertificate, clientCAs *x509.CertPool) *httptest.Server {
+	serverCertificate := newTestCertificate(t, "prometheus", ca, x509.Certificate{
+		DNSNames:    []string{"prometheus.internal"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status": "success", "data": {"resultType": "scalar", "result": [1700000000, "1"]}}`))
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{serverCertificate.tls}}
+	if clientCAs != nil {
+		server.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+		server.TLS.ClientCAs = clientCAs
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func query(instance *Instance) error {
+	client, err := instance.GetApiClient()
+	if err != nil {
+		return err
+	}
+	_, _, err = client.Query(context.Background(), "1", time.Now())
+	return err
+}
+
+func TestTLSConfig_Validate(t *testing.T) {
+	assert.NoError(t, (&TLSConfig{CaFile: "ca.pem", MinVersion: "1.3"}).validate())
+	assert.ErrorContains(t, (&TLSConfig{CertFile: "cert.pem"}).validate(), "certFile and keyFile must be configured together")
+	assert.ErrorContains(t, (&TLSConfig{MinVersion: "1.4"}).validate(), "unsupported minVersion '1.4'")
+}
+
+func TestInstance_GetApiClient_WithCaFile(t *testing.T) {
+	ca := newTestCA(t)
+	server := newTLSServer(t, ca, nil)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeCertificate(t, caFile, ca)
+
+	t.Run("trusts the configured CA", func(t *testing.T) {
+		assert.NoError(t, query(&Instance{Name: "internal", BaseUrl: server.URL, TLS: &TLSConfig{CaFile: caFile}}))
+	})
+
+	t.Run("uses system roots without CA file", func(t *testing.T) {
+		assert.ErrorContains(t, query(&Instance{Name: "internal", BaseUrl: server.URL}), "certificate")
+	})
+
+	t.Run("verifies the host", func(t *testing.T) {
+		otherCa := newTestCA(t)
+		otherServer := newTLSServer(t, otherCa, nil)
+		otherCaFile := filepath.Join(t.TempDir(), "ca.pem")
+		writeCertificate(t, otherCaFile, otherCa)
+		localhostUrl := strings.Replace(otherServer.URL, "127.0.0.1", "localhost", 1)
+		assert.ErrorContains(t, query(&Instance{Name: "internal", BaseUrl: localhostUrl, TLS: &TLSConfig{CaFile: otherCaFile}}), "localhost")
+	})
+
+	t.Run("verifies the server name", func(t *testing.T) {
+		assert.NoError(t, query(&Instance{Name: "internal", BaseUrl: server.URL, TLS: &TLSConfig{CaFile: caFile, ServerName: "prometheus.internal"}}))
+		assert.ErrorContains(t, query(&Instance{Name: "internal", BaseUrl: server.URL, TLS: &TLSConfig{CaFile: caFile, ServerName: "other.internal"}}), "other.internal")
+	})
+
+	t.Run("enforces the minimum version", func(t *testing.T) {
+		server.TLS.MaxVersion = tls.VersionTLS12
+		defer func() { server.TLS.MaxVersion = 0 }()
+		server.CloseClientConnections()
+		assert.ErrorContains(t, query(&Instance{Name: "internal", BaseUrl: server.URL, TLS: &TLSConfig{CaFile: caFile, MinVersion: "1.3"}}), "protocol version")
+	})
+}
+
+func TestInstance_GetApiClient_WithClientCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.certificate)
+	server := newTLSServer(t, ca, clientCAs)
+
+	dir := t.TempDir()
+	caFile, certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCertificate(t, caFile, ca)
+	client := newTestCertificate(t, "extension", ca, x509.Certificate{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	writeCertificate(t, certFile, client)
+	writeKey(t, keyFile, client)
+
+	assert.NoError(t, query(&Instance{Name: "mtls", BaseUrl: server.URL, TLS: &TLSConfig{CaFile: caFile, CertFile: certFile, KeyFile: keyFile}}))
+	assert.Error(t, query(&Instance{Name: "mtls", BaseUrl: server.URL, TLS: &TLSConfig{CaFile: caFile}}))
+
+	_, err := (&Instance{Name: "mtls", BaseUrl: server.URL, TLS: &TLSConfig{CertFile: filepath.Join(dir, "missing.crt"), KeyFile: keyFile}}).GetApiClient()
+	assert.ErrorContains(t, err, "invalid TLS config of instance 'mtls'")
+}
+
+func TestTLSFiles_ReloadsChangedFiles(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	first, second := newTestCA(t), newTestCA(t)
+	writeCertificate(t, caFile, first)
+
+	files := &tlsFiles{caFile: caFile}
+	rootCAs, _, err := files.load()
+	require.NoError(t, err)
+	assert.True(t, rootCAs.Equal(poolOf(first)))
+
+	writeCertificate(t, caFile, second)
+	require.NoError(t, os.Chtimes(caFile, time.Now(), time.Now().Add(time.Minute)))
+	rootCAs, _, err = files.load()
+	require.NoError(t, err)
+	assert.True(t, rootCAs.Equal(poolOf(second)))
+
+	// An invalid file keeps the previously loaded CAs
+	require.NoError(t, os.WriteFile(caFile, []byte("garbage"), 0o600))
+	require.NoError(t, os.Chtimes(caFile, time.Now(), time.Now().Add(2*time.Minute)))
+	rootCAs, _, err = files.load()
+	require.NoError(t, err)
+	assert.True(t, rootCAs.Equal(poolOf(second)))
+}
+
+func poolOf(certificate *testCertificate) *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(certificate.certificate)
+	return pool
+}
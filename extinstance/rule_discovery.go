@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extinstance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	prometheus "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/rs/zerolog/log"
+	"github.com/steadybit/discovery-kit/go/discovery_kit_api"
+	"github.com/steadybit/discovery-kit/go/discovery_kit_commons"
+	"github.com/steadybit/discovery-kit/go/discovery_kit_sdk"
+	"github.com/steadybit/extension-kit/extbuild"
+	"github.com/steadybit/extension-prometheus/v2/config"
+)
+
+type ruleDiscovery struct {
+}
+
+var (
+	_ discovery_kit_sdk.TargetDescriber    = (*ruleDiscovery)(nil)
+	_ discovery_kit_sdk.AttributeDescriber = (*ruleDiscovery)(nil)
+)
+
+func NewRuleDiscovery() discovery_kit_sdk.TargetDiscovery {
+	discovery := &ruleDiscovery{}
+	return discovery_kit_sdk.NewCachedTargetDiscovery(discovery,
+		discovery_kit_sdk.WithRefreshTargetsNow(),
+		discovery_kit_sdk.WithRefreshTargetsInterval(context.Background(), 60*time.Second),
+	)
+}
+
+func (d *ruleDiscovery) Describe() discovery_kit_api.DiscoveryDescription {
+	return discovery_kit_api.DiscoveryDescription{
+		Id: PrometheusRuleTargetId,
+		Discover: discovery_kit_api.DescribingEndpointReferenceWithCallInterval{
+			CallInterval: new("60s"),
+		},
+	}
+}
+
+func (d *ruleDiscovery) DescribeTarget() discovery_kit_api.TargetDescription {
+	return discovery_kit_api.TargetDescription{
+		Id:       PrometheusRuleTargetId,
+		Label:    discovery_kit_api.PluralLabel{One: "Prometheus Rule", Other: "Prometheus Rules"},
+		Category: new("monitoring"),
+		Version:  extbuild.GetSemverVersionStringOrUnknown(),
+		Icon:     new(PrometheusIcon),
+		Table: discovery_kit_api.Table{
+			Columns: []discovery_kit_api.Column{
+				{Attribute: "prometheus.rule.name"},
+				{Attribute: "prometheus.rule.group"},
+				{Attribute: "prometheus.rule.type"},
+				{Attribute: "prometheus.rule.severity"},
+				{Attribute: "prometheus.instance.name"},
+			},
+			OrderBy: []discovery_kit_api.OrderBy{
+				{
+					Attribute: "prometheus.rule.name",
+					Direction: "ASC",
+				},
+			},
+		},
+	}
+}
+
+func (d *ruleDiscovery) DescribeAttributes() []discovery_kit_api.AttributeDescription {
+	return []discovery_kit_api.AttributeDescription{
+		{
+			Attribute: "prometheus.rule.name",
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Prometheus rule name",
+				Other: "Prometheus rule names",
+			},
+		}, {
+			Attribute: "prometheus.rule.group",
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Prometheus rule group",
+				Other: "Prometheus rule groups",
+			},
+		}, {
+			Attribute: "prometheus.rule.type",
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Prometheus rule type",
+				Other: "Prometheus rule types",
+			},
+		}, {
+			Attribute: "prometheus.rule.severity",
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Prometheus rule severity",
+				Other: "Prometheus rule severities",
+			},
+		}, {
+			Attribute: "prometheus.rule.health",
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Prometheus rule health",
+				Other: "Prometheus rule health",
+			},
+		}, {
+			Attribute: "prometheus.rule.state",
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Prometheus alert state",
+				Other: "Prometheus alert states",
+			},
+		},
+	}
+}
+
+func (d *ruleDiscovery) DiscoverTargets(ctx context.Context) ([]discovery_kit_api.Target, error) {
+	var targets []discovery_kit_api.Target
+
+	for _, instance := range GetInstances() {
+		client, err := instance.GetApiClient()
+		if err != nil {
+			log.Warn().Err(err).Str("instance", instance.Name).Msg("Failed to initialize Prometheus API client for rule discovery")
+			continue
+		}
+		rules, err := client.Rules(ctx, nil)
+		if err != nil {
+			log.Warn().Err(err).Str("instance", instance.Name).Msg("Failed to discover Prometheus rules")
+			continue
+		}
+		targets = append(targets, toRuleTargets(instance, rules)...)
+	}
+
+	return discovery_kit_commons.ApplyAttributeExcludes(targets, config.Config.DiscoveryAttributesExcludesRule), nil
+}
+
+func toRuleTargets(instance Instance, rules prometheus.RulesResult) []discovery_kit_api.Target {
+	var targets []discovery_kit_api.Target
+	for _, group := range rules.Groups {
+		for _, rule := range group.Rules {
+			var name, ruleType, health string
+			var labels model.LabelSet
+			attributes := map[string][]string{}
+
+			switch r := rule.(type) {
+			case prometheus.AlertingRule:
+				name, ruleType, health, labels = r.Name, string(prometheus.RuleTypeAlerting), string(r.Health), r.Labels
+				attributes["prometheus.rule.state"] = []string{r.State}
+			case prometheus.RecordingRule:
+				name, ruleType, health, labels = r.Name, string(prometheus.RuleTypeRecording), string(r.Health), r.Labels
+			default:
+				continue
+			}
+
+			attributes["prometheus.instance.name"] = []string{instance.Name}
+			attributes["prometheus.rule.name"] = []string{name}
+			attributes["prometheus.rule.group"] = []string{group.Name}
+			attributes["prometheus.rule.type"] = []string{ruleType}
+			attributes["prometheus.rule.health"] = []string{health}
+			if severity, ok := labels["severity"]; ok {
+				attributes["prometheus.rule.severity"] = []string{string(severity)}
+			}
+			for key, value := range labels {
+				attributes[fmt.Sprintf("prometheus.rule.label.%s", key)] = []string{string(value)}
+			}
+
+			targets = append(targets, discovery_kit_api.Target{
+				// Rules of a group may share their name, e.g., a warning and a critical variant of an alert, but not their
+				// labels. The fingerprint of the labels tells them apart and, unlike their index, survives reordering.
+				Id:         fmt.Sprintf("%s/%s/%s/%s/%s", instance.Name, group.File, group.Name, name, labels.Fingerprint()),
+				Label:      name,
+				TargetType: PrometheusRuleTargetId,
+				Attributes: attributes,
+			})
+		}
+	}
+	return targets
+}
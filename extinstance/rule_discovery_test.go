@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extinstance
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRules = `{
+  "status": "success",
+  "data": {
+    "groups": [
+      {
+        "name": "shop.rules",
+        "file": "/etc/prometheus/rules.yml",
+        "interval": 30,
+        "rules": [
+          {
+            "type": "alerting",
+            "name": "HighErrorRate",
+            "query": "rate(http_requests_total{code=~\"5..\"}[1m]) > 0.1",
+            "duration": 60,
+            "labels": {"severity": "critical", "team": "checkout"},
+            "annotations": {},
+            "alerts": [],
+            "health": "ok",
+            "state": "inactive"
+          },
+          {
+            "type": "alerting",
+            "name": "HighErrorRate",
+            "query": "rate(http_requests_total{code=~\"5..\"}[1m]) > 0.05",
+            "duration": 60,
+            "labels": {"severity": "warning", "team": "checkout"},
+            "annotations": {},
+            "alerts": [],
+            "health": "ok",
+            "state": "inactive"
+          },
+          {
+            "type": "recording",
+            "name": "job:http_requests:rate1m",
+            "query": "sum by (job) (rate(http_requests_total[1m]))",
+            "health": "ok"
+          }
+        ]
+      }
+    ]
+  }
+}`
+
+func TestRuleDiscovery_DiscoverTargets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/rules", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testRules))
+	}))
+	defer server.Close()
+
+	originalInstances := GetInstances()
+	defer SetInstances(originalInstances)
+	SetInstances([]Instance{
+		{Name: "prometheus-1", BaseUrl: server.URL},
+		{Name: "unreachable", BaseUrl: "http://127.0.0.1:1"},
+	})
+
+	targets, err := (&ruleDiscovery{}).DiscoverTargets(context.Background())
+	require.NoError(t, err)
+	require.Len(t, targets, 3)
+
+	alerting := targets[0]
+	assert.Equal(t, "prometheus-1//etc/prometheus/rules.yml/shop.rules/HighErrorRate/"+model.LabelSet{"severity": "critical", "team": "checkout"}.Fingerprint().String(), alerting.Id)
+	assert.Equal(t, "HighErrorRate", alerting.Label)
+	assert.Equal(t, PrometheusRuleTargetId, alerting.TargetType)
+	assert.Equal(t, []string{"prometheus-1"}, alerting.Attributes["prometheus.instance.name"])
+	assert.Equal(t, []string{"shop.rules"}, alerting.Attributes["prometheus.rule.group"])
+	assert.Equal(t, []string{"alerting"}, alerting.Attributes["prometheus.rule.type"])
+	assert.Equal(t, []string{"critical"}, alerting.Attributes["prometheus.rule.severity"])
+	assert.Equal(t, []string{"inactive"}, alerting.Attributes["prometheus.rule.state"])
+	assert.Equal(t, []string{"checkout"}, alerting.Attributes["prometheus.rule.label.team"])
+
+	warning := targets[1]
+	assert.Equal(t, "HighErrorRate", warning.Label)
+	assert.Equal(t, []string{"warning"}, warning.Attributes["prometheus.rule.severity"])
+	assert.NotEqual(t, alerting.Id, warning.Id)
+
+	recording := targets[2]
+	assert.Equal(t, "job:http_requests:rate1m", recording.Label)
+	assert.Equal(t, []string{"recording"}, recording.Attributes["prometheus.rule.type"])
+	assert.NotContains(t, recording.Attributes, "prometheus.rule.severity")
+}
@@ -0,0 +1,244 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extinstance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	prometheus "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/rs/zerolog/log"
+	"github.com/steadybit/discovery-kit/go/discovery_kit_api"
+	"github.com/steadybit/discovery-kit/go/discovery_kit_commons"
+	"github.com/steadybit/discovery-kit/go/discovery_kit_sdk"
+	"github.com/steadybit/extension-kit/extbuild"
+	"github.com/steadybit/extension-prometheus/v2/config"
+)
+
+const (
+	kubernetesPodTargetId = "com.steadybit.extension_kubernetes.kubernetes-pod"
+	hostTargetId          = "com.steadybit.extension_host.host"
+)
+
+type scrapeTargetDiscovery struct {
+}
+
+var (
+	_ discovery_kit_sdk.TargetDescriber          = (*scrapeTargetDiscovery)(nil)
+	_ discovery_kit_sdk.AttributeDescriber       = (*scrapeTargetDiscovery)(nil)
+	_ discovery_kit_sdk.EnrichmentRulesDescriber = (*scrapeTargetDiscovery)(nil)
+)
+
+func NewScrapeTargetDiscovery() discovery_kit_sdk.TargetDiscovery {
+	discovery := &scrapeTargetDiscovery{}
+	return discovery_kit_sdk.NewCachedTargetDiscovery(discovery,
+		discovery_kit_sdk.WithRefreshTargetsNow(),
+		discovery_kit_sdk.WithRefreshTargetsInterval(context.Background(), 30*time.Second),
+	)
+}
+
+func (d *scrapeTargetDiscovery) Describe() discovery_kit_api.DiscoveryDescription {
+	return discovery_kit_api.DiscoveryDescription{
+		Id: PrometheusScrapeTargetId,
+		Discover: discovery_kit_api.DescribingEndpointReferenceWithCallInterval{
+			CallInterval: new("30s"),
+		},
+	}
+}
+
+func (d *scrapeTargetDiscovery) DescribeTarget() discovery_kit_api.TargetDescription {
+	return discovery_kit_api.TargetDescription{
+		Id:       PrometheusScrapeTargetId,
+		Label:    discovery_kit_api.PluralLabel{One: "Prometheus Scrape Target", Other: "Prometheus Scrape Targets"},
+		Category: new("monitoring"),
+		Version:  extbuild.GetSemverVersionStringOrUnknown(),
+		Icon:     new(PrometheusIcon),
+		Table: discovery_kit_api.Table{
+			Columns: []discovery_kit_api.Column{
+				{Attribute: "prometheus.scrape.instance"},
+				{Attribute: "prometheus.scrape.job"},
+				{Attribute: "prometheus.scrape.health"},
+				{Attribute: "prometheus.instance.name"},
+			},
+			OrderBy: []discovery_kit_api.OrderBy{
+				{
+					Attribute: "prometheus.scrape.job",
+					Direction: "ASC",
+				},
+			},
+		},
+	}
+}
+
+func (d *scrapeTargetDiscovery) DescribeAttributes() []discovery_kit_api.AttributeDescription {
+	return []discovery_kit_api.AttributeDescription{
+		{
+			Attribute: "prometheus.scrape.job",
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Prometheus scrape job",
+				Other: "Prometheus scrape jobs",
+			},
+		}, {
+			Attribute: "prometheus.scrape.instance",
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Prometheus scrape instance",
+				Other: "Prometheus scrape instances",
+			},
+		}, {
+			Attribute: "prometheus.scrape.url",
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Prometheus scrape URL",
+				Other: "Prometheus scrape URLs",
+			},
+		}, {
+			Attribute: "prometheus.scrape.health",
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Prometheus scrape health",
+				Other: "Prometheus scrape health",
+			},
+		}, {
+			Attribute: "prometheus.scrape.last-error",
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Prometheus scrape error",
+				Other: "Prometheus scrape errors",
+			},
+		}, {
+			Attribute: "prometheus.scrape.namespace",
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Prometheus scrape namespace",
+				Other: "Prometheus scrape namespaces",
+			},
+		}, {
+			Attribute: "prometheus.scrape.pod",
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Prometheus scrape pod",
+				Other: "Prometheus scrape pods",
+			},
+		}, {
+			Attribute: "prometheus.scrape.node",
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Prometheus scrape node",
+				Other: "Prometheus scrape nodes",
+			},
+		},
+	}
+}
+
+func (d *scrapeTargetDiscovery) DescribeEnrichmentRules() []discovery_kit_api.TargetEnrichmentRule {
+	return []discovery_kit_api.TargetEnrichmentRule{
+		{
+			Id:      "com.steadybit.extension_prometheus.scrape-target-to-kubernetes-pod",
+			Version: extbuild.GetSemverVersionStringOrUnknown(),
+			Src: discovery_kit_api.SourceOrDestination{
+				Type: PrometheusScrapeTargetId,
+				Selector: map[string]string{
+					"prometheus.scrape.namespace": "${dest.k8s.namespace}",
+					"prometheus.scrape.pod":       "${dest.k8s.pod.name}",
+				},
+			},
+			Dest: discovery_kit_api.SourceOrDestination{
+				Type: kubernetesPodTargetId,
+				Selector: map[string]string{
+					"k8s.namespace": "${src.prometheus.scrape.namespace}",
+					"k8s.pod.name":  "${src.prometheus.scrape.pod}",
+				},
+			},
+			Attributes: scrapeEnrichmentAttributes(),
+		},
+		{
+			Id:      "com.steadybit.extension_prometheus.scrape-target-to-host",
+			Version: extbuild.GetSemverVersionStringOrUnknown(),
+			Src: discovery_kit_api.SourceOrDestination{
+				Type: PrometheusScrapeTargetId,
+				Selector: map[string]string{
+					"prometheus.scrape.node": "${dest.host.hostname}",
+				},
+			},
+			Dest: discovery_kit_api.SourceOrDestination{
+				Type: hostTargetId,
+				Selector: map[string]string{
+					"host.hostname": "${src.prometheus.scrape.node}",
+				},
+			},
+			Attributes: scrapeEnrichmentAttributes(),
+		},
+	}
+}
+
+func scrapeEnrichmentAttributes() []discovery_kit_api.Attribute {
+	return []discovery_kit_api.Attribute{
+		{Matcher: discovery_kit_api.Equals, Name: "prometheus.scrape.health"},
+		{Matcher: discovery_kit_api.Equals, Name: "prometheus.scrape.job"},
+	}
+}
+
+func (d *scrapeTargetDiscovery) DiscoverTargets(ctx context.Context) ([]discovery_kit_api.Target, error) {
+	var targets []discovery_kit_api.Target
+
+	for _, instance := range GetInstances() {
+		client, err := instance.GetApiClient()
+		if err != nil {
+			log.Warn().Err(err).Str("instance", instance.Name).Msg("Failed to initialize Prometheus API client for scrape target discovery")
+			continue
+		}
+		result, err := client.Targets(ctx)
+		if err != nil {
+			log.Warn().Err(err).Str("instance", instance.Name).Msg("Failed to discover Prometheus scrape targets")
+			continue
+		}
+		for _, target := range result.Active {
+			targets = append(targets, toScrapeTarget(instance, target))
+		}
+	}
+
+	return discovery_kit_commons.ApplyAttributeExcludes(targets, config.Config.DiscoveryAttributesExcludesScrapeTarget), nil
+}
+
+func toScrapeTarget(instance Instance, target prometheus.ActiveTarget) discovery_kit_api.Target {
+	attributes := map[string][]string{
+		"prometheus.instance.name":   {instance.Name},
+		"prometheus.scrape.job":      {string(target.Labels["job"])},
+		"prometheus.scrape.instance": {string(target.Labels["instance"])},
+		"prometheus.scrape.url":      {target.ScrapeURL},
+		"prometheus.scrape.pool":     {target.ScrapePool},
+		"prometheus.scrape.health":   {string(target.Health)},
+	}
+	if target.LastError != "" {
+		attributes["prometheus.scrape.last-error"] = []string{target.LastError}
+	}
+	if namespace := labelOrDiscovered(target, "namespace", "__meta_kubernetes_namespace"); namespace != "" {
+		attributes["prometheus.scrape.namespace"] = []string{namespace}
+	}
+	if pod := labelOrDiscovered(target, "pod", "__meta_kubernetes_pod_name"); pod != "" {
+		attributes["prometheus.scrape.pod"] = []string{pod}
+	}
+	if node := labelOrDiscovered(target, "node", "__meta_kubernetes_pod_node_name", "__meta_kubernetes_node_name"); node != "" {
+		attributes["prometheus.scrape.node"] = []string{node}
+	}
+	for key, value := range target.Labels {
+		attributes[fmt.Sprintf("prometheus.scrape.label.%s", key)] = []string{string(value)}
+	}
+
+	return discovery_kit_api.Target{
+		Id:         fmt.Sprintf("%s/%s/%s", instance.Name, target.ScrapePool, target.ScrapeURL),
+		Label:      fmt.Sprintf("%s (%s)", target.Labels["instance"], target.Labels["job"]),
+		TargetType: PrometheusScrapeTargetId,
+		Attributes: attributes,
+	}
+}
+
+// labelOrDiscovered returns the value of the target label, falling back to the first non-empty discovered label.
+func labelOrDiscovered(target prometheus.ActiveTarget, label string, discoveredLabels ...string) string {
+	if value := string(target.Labels[model.LabelName(label)]); value != "" {
+		return value
+	}
+	for _, discovered := range discoveredLabels {
+		if value := target.DiscoveredLabels[discovered]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
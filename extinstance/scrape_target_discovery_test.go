@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extinstance
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTargets = `{
+  "status": "success",
+  "data": {
+    "activeTargets": [
+      {
+        "discoveredLabels": {"__meta_kubernetes_namespace": "shop", "__meta_kubernetes_pod_name": "checkout-7d9f", "__meta_kubernetes_pod_node_name": "node-1"},
+        "labels": {"instance": "10.0.0.12:8080", "job": "checkout"},
+        "scrapePool": "kubernetes-pods",
+        "scrapeUrl": "http://10.0.0.12:8080/metrics",
+        "lastError": "",
+        "lastScrape": "2026-01-01T10:00:00Z",
+        "lastScrapeDuration": 0.01,
+        "health": "up"
+      },
+      {
+        "discoveredLabels": {},
+        "labels": {"instance": "node-2:9100", "job": "node", "namespace": "monitoring", "node": "node-2"},
+        "scrapePool": "node-exporter",
+        "scrapeUrl": "http://node-2:9100/metrics",
+        "lastError": "connection refused",
+        "lastScrape": "2026-01-01T10:00:00Z",
+        "lastScrapeDuration": 0.01,
+        "health": "down"
+      }
+    ],
+    "droppedTargets": []
+  }
+}`
+
+func TestScrapeTargetDiscovery_DiscoverTargets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/targets", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testTargets))
+	}))
+	defer server.Close()
+
+	originalInstances := GetInstances()
+	defer SetInstances(originalInstances)
+	SetInstances([]Instance{{Name: "prometheus-1", BaseUrl: server.URL}})
+
+	targets, err := (&scrapeTargetDiscovery{}).DiscoverTargets(context.Background())
+	require.NoError(t, err)
+	require.Len(t, targets, 2)
+
+	pod := targets[0]
+	assert.Equal(t, "prometheus-1/kubernetes-pods/http://10.0.0.12:8080/metrics", pod.Id)
+	assert.Equal(t, "10.0.0.12:8080 (checkout)", pod.Label)
+	assert.Equal(t, PrometheusScrapeTargetId, pod.TargetType)
+	assert.Equal(t, []string{"up"}, pod.Attributes["prometheus.scrape.health"])
+	assert.Equal(t, []string{"shop"}, pod.Attributes["prometheus.scrape.namespace"])
+	assert.Equal(t, []string{"checkout-7d9f"}, pod.Attributes["prometheus.scrape.pod"])
+	assert.Equal(t, []string{"node-1"}, pod.Attributes["prometheus.scrape.node"])
+	assert.NotContains(t, pod.Attributes, "prometheus.scrape.last-error")
+
+	node := targets[1]
+	assert.Equal(t, []string{"down"}, node.Attributes["prometheus.scrape.health"])
+	assert.Equal(t, []string{"connection refused"}, node.Attributes["prometheus.scrape.last-error"])
+	assert.Equal(t, []string{"monitoring"}, node.Attributes["prometheus.scrape.namespace"])
+	assert.Equal(t, []string{"node-2"}, node.Attributes["prometheus.scrape.node"])
+	assert.NotContains(t, node.Attributes, "prometheus.scrape.pod")
+	assert.Equal(t, []string{"node"}, node.Attributes["prometheus.scrape.label.job"])
+}
+
+func TestScrapeTargetDiscovery_DescribeEnrichmentRules(t *testing.T) {
+	rules := (&scrapeTargetDiscovery{}).DescribeEnrichmentRules()
+	require.Len(t, rules, 2)
+
+	for _, rule := range rules {
+		assert.Equal(t, PrometheusScrapeTargetId, rule.Src.Type)
+		assert.Contains(t, []string{kubernetesPodTargetId, hostTargetId}, rule.Dest.Type)
+		assert.Equal(t, "prometheus.scrape.health", rule.Attributes[0].Name)
+	}
+}
@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extmetric
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/steadybit/extension-prometheus/v2/extinstance"
+)
+
+const (
+	comparisonThreshold = "threshold"
+	comparisonBaseline  = "baseline"
+
+	toleranceRelative = "percent"
+	toleranceAbsolute = "absolute"
+
+	// baselinePoints is the number of points per series the baseline is averaged over.
+	baselinePoints = 60
+)
+
+// baselineComparison compares the value of each series against its average before the check started, instead of a
+// fixed threshold. The operator of the condition decides in which direction the value may deviate by the tolerance,
+// e.g., "<=" with a relative tolerance of 20 fails once a value is more than 20% above its baseline.
+type baselineComparison struct {
+	// Window is the duration in milliseconds before the start of the check the baseline is averaged over.
+	Window    int64   `json:"window"`
+	Tolerance float64 `json:"tolerance"`
+	Relative  bool    `json:"relative"`
+	// Values are the baselines by the labels of the series. They are recorded once the check starts.
+	Values map[string]float64 `json:"values,omitempty"`
+}
+
+func parseBaselineComparison(comparison string, window int64, tolerance string, toleranceType string) (*baselineComparison, error) {
+	switch comparison {
+	case "", comparisonThreshold:
+		return nil, nil
+	case comparisonBaseline:
+	default:
+		return nil, fmt.Errorf("unsupported comparison '%s', expected %s or %s", comparison, comparisonThreshold, comparisonBaseline)
+	}
+
+	if window <= 0 {
+		return nil, fmt.Errorf("baseline window must be positive")
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(tolerance), 64)
+	if err != nil || value < 0 {
+		return nil, fmt.Errorf("tolerance '%s' is not a positive number", tolerance)
+	}
+	b := &baselineComparison{Window: window, Tolerance: value}
+	switch toleranceType {
+	case "", toleranceRelative:
+		b.Relative = true
+	case toleranceAbsolute:
+	default:
+		return nil, fmt.Errorf("unsupported tolerance type '%s', expected %s or %s", toleranceType, toleranceRelative, toleranceAbsolute)
+	}
+	return b, nil
+}
+
+// isMetBy reports whether the value satisfies the operator compared to the baseline widened by the tolerance. For
+// "==" and "!=", the tolerance is the allowed or required deviation in either direction.
+func (b *baselineComparison) isMetBy(operator string, value float64, baseline float64) bool {
+	tolerance := b.Tolerance
+	if b.Relative {
+		tolerance = math.Abs(baseline) * b.Tolerance / 100
+	}
+	switch operator {
+	case operatorLess:
+		return value < baseline+tolerance
+	case operatorLessOrEqual:
+		return value <= baseline+tolerance
+	case operatorEqual:
+		return math.Abs(value-baseline) <= tolerance
+	case operatorNotEqual:
+		return math.Abs(value-baseline) > tolerance
+	case operatorGreaterOrEqual:
+		return value >= baseline-tolerance
+	case operatorGreater:
+		return value > baseline-tolerance
+	default:
+		return false
+	}
+}
+
+func (b *baselineComparison) describe(operator string) string {
+	tolerance := strconv.FormatFloat(b.Tolerance, 'g', -1, 64)
+	if b.Relative {
+		tolerance += "%"
+	}
+	switch operator {
+	case operatorLess, operatorLessOrEqual:
+		return fmt.Sprintf("%s baseline + %s", operator, tolerance)
+	case operatorGreater, operatorGreaterOrEqual:
+		return fmt.Sprintf("%s baseline - %s", operator, tolerance)
+	case operatorEqual:
+		return fmt.Sprintf("within %s of baseline", tolerance)
+	default:
+		return fmt.Sprintf("more than %s off baseline", tolerance)
+	}
+}
+
+// queryBaseline averages each series of the query over the window before the given time.
+func queryBaseline(ctx context.Context, instance *extinstance.Instance, client v1.API, query string, window time.Duration, end time.Time) (map[string]float64, error) {
+	r := v1.Range{
+		Start: end.Add(-window),
+		End:   end,
+		Step:  max(window/baselinePoints, time.Second),
+	}
+	var matrix model.Matrix
+	err := instance.Retry(ctx, func(ctx context.Context) error {
+		value, _, err := client.QueryRange(ctx, query, r)
+		if err != nil {
+			return err
+		}
+		result, ok := value.(model.Matrix)
+		if !ok {
+			return fmt.Errorf("expected matrix as range query result, but got %s", value.Type())
+		}
+		matrix = result
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	instance.RecordQueryResult(matrix)
+
+	baselines := make(map[string]float64, len(matrix))
+	for _, stream := range matrix {
+		sum, count := 0.0, 0
+		for _, pair := range stream.Values {
+			if !math.IsNaN(float64(pair.Value)) {
+				sum += float64(pair.Value)
+				count++
+			}
+		}
+		if count > 0 {
+			baselines[stream.Metric.String()] = sum / float64(count)
+		}
+	}
+	return baselines, nil
+}
+
+// evaluateBaseline checks every sample against the baseline of its series and returns the samples which do not
+// satisfy the operator together with the verdict of the series requirement. Series without a baseline fail.
+func evaluateBaseline(samples model.Vector, operator string, b *baselineComparison, r seriesRequirement) (bool, model.Vector) {
+	var failing model.Vector
+	for _, sample := range samples {
+		baseline, ok := b.Values[sample.Metric.String()]
+		if !ok || !b.isMetBy(operator, float64(sample.Value), baseline) {
+			failing = append(failing, sample)
+		}
+	}
+	return r.isMetBy(len(samples)-len(failing), len(samples)), failing
+}
+
+func describeBaselineSeries(samples model.Vector, b *baselineComparison) string {
+	parts := make([]string, 0, min(len(samples), maxReportedSeries))
+	for i, sample := range samples {
+		if i == maxReportedSeries {
+			parts = append(parts, fmt.Sprintf("and %d more", len(samples)-maxReportedSeries))
+			break
+		}
+		baseline, ok := b.Values[sample.Metric.String()]
+		if !ok {
+			parts = append(parts, fmt.Sprintf("%s = %s (no baseline)", sample.Metric, sample.Value))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s = %s (baseline %s)", sample.Metric, sample.Value, strconv.FormatFloat(baseline, 'g', 6, 64)))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extmetric
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/steadybit/action-kit/go/action_kit_sdk"
+	"github.com/steadybit/extension-prometheus/v2/extinstance"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBaselineComparison(t *testing.T) {
+	b, err := parseBaselineComparison(comparisonThreshold, 300000, "20", toleranceRelative)
+	require.NoError(t, err)
+	assert.Nil(t, b)
+
+	b, err = parseBaselineComparison(comparisonBaseline, 300000, " 20 ", "")
+	require.NoError(t, err)
+	assert.Equal(t, &baselineComparison{Window: 300000, Tolerance: 20, Relative: true}, b)
+
+	b, err = parseBaselineComparison(comparisonBaseline, 60000, "0.5", toleranceAbsolute)
+	require.NoError(t, err)
+	assert.Equal(t, &baselineComparison{Window: 60000, Tolerance: 0.5}, b)
+
+	_, err = parseBaselineComparison("yesterday", 300000, "20", toleranceRelative)
+	assert.ErrorContains(t, err, "unsupported comparison 'yesterday'")
+	_, err = parseBaselineComparison(comparisonBaseline, 0, "20", toleranceRelative)
+	assert.ErrorContains(t, err, "baseline window must be positive")
+	_, err = parseBaselineComparison(comparisonBaseline, 300000, "-1", toleranceRelative)
+	assert.ErrorContains(t, err, "is not a positive number")
+	_, err = parseBaselineComparison(comparisonBaseline, 300000, "20", "factor")
+	assert.ErrorContains(t, err, "unsupported tolerance type 'factor'")
+}
+
+func TestBaselineComparison_IsMetBy(t *testing.T) {
+	relative := &baselineComparison{Tolerance: 20, Relative: true}
+	absolute := &baselineComparison{Tolerance: 5}
+	tests := []struct {
+		comparison *baselineComparison
+		operator   string
+		value      float64
+		baseline   float64
+		want       bool
+	}{
+		{relative, operatorLessOrEqual, 120, 100, true},
+		{relative, operatorLessOrEqual, 121, 100, false},
+		{relative, operatorLess, 120, 100, false},
+		{relative, operatorGreaterOrEqual, 80, 100, true},
+		{relative, operatorGreater, 80, 100, false},
+		{relative, operatorEqual, 85, 100, true},
+		{relative, operatorEqual, 125, 100, false},
+		{relative, operatorNotEqual, 125, 100, true},
+		{relative, operatorLessOrEqual, -9, -10, true},
+		{relative, operatorLessOrEqual, -7, -10, false},
+		{absolute, operatorLessOrEqual, 105, 100, true},
+		{absolute, operatorLessOrEqual, 106, 100, false},
+		{absolute, operatorGreaterOrEqual, 95, 100, true},
+		{absolute, operatorGreaterOrEqual, 94, 100, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v %s %v of %v", tt.comparison.Relative, tt.operator, tt.value, tt.baseline), func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.comparison.isMetBy(tt.operator, tt.value, tt.baseline))
+		})
+	}
+}
+
+func TestStatus_Baseline(t *testing.T) {
+	var current string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/query_range":
+			_, _ = fmt.Fprint(w, `{"status":"success","data":{"resultType":"matrix","result":[
+				{"metric":{"pod":"a"},"values":[[1767268800,"0.1"],[1767268830,"0.3"]]},
+				{"metric":{"pod":"b"},"values":[[1767268800,"1"],[1767268830,"NaN"]]}
+			]}}`)
+		default:
+			_, _ = fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"pod":"a"},"value":[1767268860,"%s"]},
+				{"metric":{"pod":"b"},"value":[1767268860,"1.1"]}
+			]}}`, current)
+		}
+	}))
+	t.Cleanup(server.Close)
+	extinstance.SetInstances([]extinstance.Instance{{Name: "baseline", BaseUrl: server.URL}})
+	action := NewMetricCheckAction()
+
+	state := action.NewEmptyState()
+	_, err := action.Prepare(context.Background(), &state, prepareRequest("baseline", map[string]any{
+		"duration":       float64(60000),
+		"expression":     "latency",
+		"operator":       "<=",
+		"threshold":      "0",
+		"comparison":     comparisonBaseline,
+		"baselineWindow": float64(300000),
+		"tolerance":      "20",
+	}))
+	require.NoError(t, err)
+
+	_, err = action.Start(context.Background(), &state)
+	require.NoError(t, err)
+	assert.InDeltaMapValues(t, map[string]float64{`{pod="a"}`: 0.2, `{pod="b"}`: 1}, state.Baseline.Values, 1e-9)
+
+	current = "0.24"
+	result, err := action.(action_kit_sdk.ActionWithStatus[MetricCheckState]).Status(context.Background(), &state)
+	require.NoError(t, err)
+	assert.Nil(t, result.Error)
+
+	current = "0.25"
+	result, err = action.(action_kit_sdk.ActionWithStatus[MetricCheckState]).Status(context.Background(), &state)
+	require.NoError(t, err)
+	require.NotNil(t, result.Error)
+	assert.Equal(t, `Expression 'latency' met <= baseline + 20% for 1 of 2 series, expected all series. Failing series: {pod="a"} = 0.25 (baseline 0.2)`, *result.Error.Detail)
+}
+
+func TestStart_BaselineWithoutData(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{"status":"success","data":{"resultType":"matrix","result":[]}}`)
+	}))
+	t.Cleanup(server.Close)
+	extinstance.SetInstances([]extinstance.Instance{{Name: "empty", BaseUrl: server.URL}})
+	action := NewMetricCheckAction()
+
+	state := MetricCheckState{TargetName: "empty", Expression: "latency", Condition: condition{Operator: operatorLessOrEqual},
+		Baseline: &baselineComparison{Window: 300000, Tolerance: 20, Relative: true}}
+	_, err := action.Start(context.Background(), &state)
+	assert.ErrorContains(t, err, "returned no data for the baseline window of 5m0s")
+}
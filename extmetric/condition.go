@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extmetric
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/common/model"
+)
+
+const (
+	operatorLess           = "<"
+	operatorLessOrEqual    = "<="
+	operatorEqual          = "=="
+	operatorNotEqual       = "!="
+	operatorGreaterOrEqual = ">="
+	operatorGreater        = ">"
+)
+
+const (
+	seriesAggregationAll               = "all"
+	seriesAggregationAny               = "any"
+	seriesAggregationAtLeastCount      = "atLeastCount"
+	seriesAggregationAtLeastPercentage = "atLeastPercentage"
+
+	// maxReportedSeries limits the number of failing series listed in a check message.
+	maxReportedSeries = 10
+)
+
+var operators = []string{
+	operatorLess,
+	operatorLessOrEqual,
+	operatorEqual,
+	operatorNotEqual,
+	operatorGreaterOrEqual,
+	operatorGreater,
+}
+
+// condition is a comparison of a sample value against a fixed threshold, e.g. "< 0.01".
+type condition struct {
+	Operator  string  `json:"operator"`
+	Threshold float64 `json:"threshold"`
+}
+
+func parseCondition(operator string, threshold string) (condition, error) {
+	if !isValidOperator(operator) {
+		return condition{}, fmt.Errorf("unsupported operator '%s', expected one of %s", operator, strings.Join(operators, ", "))
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(threshold), 64)
+	if err != nil {
+		return condition{}, fmt.Errorf("threshold '%s' is not a number", threshold)
+	}
+	return condition{Operator: operator, Threshold: value}, nil
+}
+
+func isValidOperator(operator string) bool {
+	for _, o := range operators {
+		if o == operator {
+			return true
+		}
+	}
+	return false
+}
+
+// isMetBy reports whether the given value satisfies the condition. NaN never satisfies a condition, except for "!=".
+func (c condition) isMetBy(value float64) bool {
+	switch c.Operator {
+	case operatorLess:
+		return value < c.Threshold
+	case operatorLessOrEqual:
+		return value <= c.Threshold
+	case operatorEqual:
+		return value == c.Threshold
+	case operatorNotEqual:
+		return value != c.Threshold
+	case operatorGreaterOrEqual:
+		return value >= c.Threshold
+	case operatorGreater:
+		return value > c.Threshold
+	default:
+		return false
+	}
+}
+
+func (c condition) String() string {
+	return fmt.Sprintf("%s %s", c.Operator, strconv.FormatFloat(c.Threshold, 'g', -1, 64))
+}
+
+// seriesRequirement defines how many of the series returned by an expression must satisfy a condition.
+type seriesRequirement struct {
+	Aggregation string `json:"aggregation"`
+	// Minimum is the required number (atLeastCount) or percentage (atLeastPercentage) of satisfying series.
+	Minimum int `json:"minimum"`
+}
+
+// parseSeriesRequirement validates the series aggregation. Without a minimum, at least one series or all series
+// (100%) must meet the condition, so a percentage doesn't silently pass with a single series.
+func parseSeriesRequirement(aggregation string, minimum *int) (seriesRequirement, error) {
+	switch aggregation {
+	case "":
+		return seriesRequirement{Aggregation: seriesAggregationAll}, nil
+	case seriesAggregationAll, seriesAggregationAny:
+		return seriesRequirement{Aggregation: aggregation}, nil
+	case seriesAggregationAtLeastCount:
+		count := 1
+		if minimum != nil {
+			count = *minimum
+		}
+		if count < 1 {
+			return seriesRequirement{}, fmt.Errorf("minimum number of series must be at least 1, but was %d", count)
+		}
+		return seriesRequirement{Aggregation: aggregation, Minimum: count}, nil
+	case seriesAggregationAtLeastPercentage:
+		percentage := 100
+		if minimum != nil {
+			percentage = *minimum
+		}
+		if percentage < 1 || percentage > 100 {
+			return seriesRequirement{}, fmt.Errorf("minimum percentage of series must be between 1 and 100, but was %d", percentage)
+		}
+		return seriesRequirement{Aggregation: aggregation, Minimum: percentage}, nil
+	default:
+		return seriesRequirement{}, fmt.Errorf("unsupported series aggregation '%s'", aggregation)
+	}
+}
+
+// isMetBy reports whether enough of the total series satisfied the condition.
+func (r seriesRequirement) isMetBy(satisfied int, total int) bool {
+	switch r.Aggregation {
+	case seriesAggregationAny:
+		return satisfied > 0
+	case seriesAggregationAtLeastCount:
+		return satisfied >= r.Minimum
+	case seriesAggregationAtLeastPercentage:
+		return total > 0 && satisfied*100 >= r.Minimum*total
+	default:
+		return satisfied == total
+	}
+}
+
+func (r seriesRequirement) String() string {
+	switch r.Aggregation {
+	case seriesAggregationAny:
+		return "any series"
+	case seriesAggregationAtLeastCount:
+		return fmt.Sprintf("at least %d series", r.Minimum)
+	case seriesAggregationAtLeastPercentage:
+		return fmt.Sprintf("at least %d%% of series", r.Minimum)
+	default:
+		return "all series"
+	}
+}
+
+// evaluateSamples checks every sample against the condition and returns the samples which do not satisfy it
+// together with the verdict of the series requirement.
+func evaluateSamples(samples model.Vector, c condition, r seriesRequirement) (bool, model.Vector) {
+	var failing model.Vector
+	for _, sample := range samples {
+		if !c.isMetBy(float64(sample.Value)) {
+			failing = append(failing, sample)
+		}
+	}
+	return r.isMetBy(len(samples)-len(failing), len(samples)), failing
+}
+
+func describeSeries(samples model.Vector) string {
+	parts := make([]string, 0, min(len(samples), maxReportedSeries))
+	for i, sample := range samples {
+		if i == maxReportedSeries {
+			parts = append(parts, fmt.Sprintf("and %d more", len(samples)-maxReportedSeries))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s = %s", sample.Metric, sample.Value))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extmetric
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCondition(t *testing.T) {
+	c, err := parseCondition(">=", " 0.5 ")
+	require.NoError(t, err)
+	assert.Equal(t, condition{Operator: ">=", Threshold: 0.5}, c)
+	assert.Equal(t, ">= 0.5", c.String())
+
+	_, err = parseCondition("=>", "1")
+	assert.ErrorContains(t, err, "unsupported operator '=>'")
+
+	_, err = parseCondition("<", "one")
+	assert.ErrorContains(t, err, "threshold 'one' is not a number")
+}
+
+func TestCondition_IsMetBy(t *testing.T) {
+	tests := []struct {
+		operator string
+		value    float64
+		expected bool
+	}{
+		{operator: "<", value: 0.5, expected: true},
+		{operator: "<", value: 1, expected: false},
+		{operator: "<=", value: 1, expected: true},
+		{operator: "<=", value: 1.5, expected: false},
+		{operator: "==", value: 1, expected: true},
+		{operator: "==", value: 2, expected: false},
+		{operator: "!=", value: 2, expected: true},
+		{operator: "!=", value: 1, expected: false},
+		{operator: ">=", value: 1, expected: true},
+		{operator: ">=", value: 0.5, expected: false},
+		{operator: ">", value: 2, expected: true},
+		{operator: ">", value: 1, expected: false},
+		{operator: ">", value: math.NaN(), expected: false},
+		{operator: "!=", value: math.NaN(), expected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.operator, func(t *testing.T) {
+			c := condition{Operator: tt.operator, Threshold: 1}
+			assert.Equal(t, tt.expected, c.isMetBy(tt.value))
+		})
+	}
+}
+
+func TestSeriesRequirement_IsMetBy(t *testing.T) {
+	tests := []struct {
+		requirement seriesRequirement
+		satisfied   int
+		total       int
+		expected    bool
+	}{
+		{requirement: seriesRequirement{Aggregation: seriesAggregationAll}, satisfied: 3, total: 3, expected: true},
+		{requirement: seriesRequirement{Aggregation: seriesAggregationAll}, satisfied: 2, total: 3, expected: false},
+		{requirement: seriesRequirement{Aggregation: seriesAggregationAny}, satisfied: 1, total: 3, expected: true},
+		{requirement: seriesRequirement{Aggregation: seriesAggregationAny}, satisfied: 0, total: 3, expected: false},
+		{requirement: seriesRequirement{Aggregation: seriesAggregationAtLeastCount, Minimum: 2}, satisfied: 2, total: 5, expected: true},
+		{requirement: seriesRequirement{Aggregation: seriesAggregationAtLeastCount, Minimum: 2}, satisfied: 1, total: 5, expected: false},
+		{requirement: seriesRequirement{Aggregation: seriesAggregationAtLeastPercentage, Minimum: 50}, satisfied: 2, total: 4, expected: true},
+		{requirement: seriesRequirement{Aggregation: seriesAggregationAtLeastPercentage, Minimum: 50}, satisfied: 1, total: 3, expected: false},
+		{requirement: seriesRequirement{Aggregation: seriesAggregationAtLeastPercentage, Minimum: 50}, satisfied: 0, total: 0, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %d of %d", tt.requirement, tt.satisfied, tt.total), func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.requirement.isMetBy(tt.satisfied, tt.total))
+		})
+	}
+}
+
+func TestParseSeriesRequirement(t *testing.T) {
+	r, err := parseSeriesRequirement("", nil)
+	require.NoError(t, err)
+	assert.Equal(t, seriesRequirement{Aggregation: seriesAggregationAll}, r)
+
+	r, err = parseSeriesRequirement(seriesAggregationAtLeastCount, new(3))
+	require.NoError(t, err)
+	assert.Equal(t, seriesRequirement{Aggregation: seriesAggregationAtLeastCount, Minimum: 3}, r)
+
+	r, err = parseSeriesRequirement(seriesAggregationAtLeastCount, nil)
+	require.NoError(t, err)
+	assert.Equal(t, seriesRequirement{Aggregation: seriesAggregationAtLeastCount, Minimum: 1}, r)
+
+	r, err = parseSeriesRequirement(seriesAggregationAtLeastPercentage, nil)
+	require.NoError(t, err)
+	assert.Equal(t, seriesRequirement{Aggregation: seriesAggregationAtLeastPercentage, Minimum: 100}, r)
+
+	_, err = parseSeriesRequirement(seriesAggregationAtLeastCount, new(0))
+	assert.ErrorContains(t, err, "must be at least 1")
+
+	_, err = parseSeriesRequirement(seriesAggregationAtLeastPercentage, new(101))
+	assert.ErrorContains(t, err, "between 1 and 100")
+
+	_, err = parseSeriesRequirement("most", new(1))
+	assert.ErrorContains(t, err, "unsupported series aggregation 'most'")
+}
+
+func TestDescribeSeries(t *testing.T) {
+	var samples model.Vector
+	for i := 0; i < maxReportedSeries+2; i++ {
+		samples = append(samples, &model.Sample{Metric: model.Metric{"pod": model.LabelValue(fmt.Sprintf("pod-%d", i))}, Value: 1})
+	}
+
+	assert.Equal(t, `{pod="pod-0"} = 1`, describeSeries(samples[:1]))
+	assert.Contains(t, describeSeries(samples), `{pod="pod-9"} = 1, and 2 more`)
+}
@@ -6,6 +6,7 @@ package extmetric
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,26 +14,38 @@ import (
 
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
-	retry "github.com/sethvargo/go-retry"
 	"github.com/steadybit/action-kit/go/action_kit_api/v2"
 	"github.com/steadybit/action-kit/go/action_kit_sdk"
 	extension_kit "github.com/steadybit/extension-kit"
 	"github.com/steadybit/extension-kit/extbuild"
 	"github.com/steadybit/extension-kit/extutil"
-	"github.com/steadybit/extension-prometheus/v2/config"
+	"github.com/steadybit/extension-prometheus/v2/extcheck"
 	"github.com/steadybit/extension-prometheus/v2/extinstance"
+	"github.com/steadybit/extension-prometheus/v2/exttracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type MetricCheckAction struct {
 }
 
 type MetricCheckState struct {
-	Command         []string  `json:"command"`
-	Pid             int       `json:"pid"`
-	CmdStateID      string    `json:"cmdStateId"`
-	Timestamp       string    `json:"timestamp"`
-	StdOutLineCount int       `json:"stdOutLineCount"`
-	ExecutionId     uuid.UUID `json:"executionId"`
+	ExecutionId uuid.UUID         `json:"executionId"`
+	TargetName  string            `json:"targetName"`
+	Duration    int64             `json:"duration"`
+	End         time.Time         `json:"end"`
+	Expression  string            `json:"expression"`
+	Condition   condition         `json:"condition"`
+	Series      seriesRequirement `json:"series"`
+	CheckMode   string            `json:"checkMode"`
+	// Tenant overrides the tenant configured for the instance, if set.
+	Tenant string `json:"tenant,omitempty"`
+	// ConditionMet is set once an evaluation satisfied the condition. Only relevant for extcheck.ModeAtLeastOnce.
+	ConditionMet bool `json:"conditionMet"`
+	// LastViolation describes the most recent evaluation which did not satisfy the condition.
+	LastViolation string `json:"lastViolation,omitempty"`
+	// Baseline compares the expression against its values before the check instead of the threshold, if set.
+	Baseline *baselineComparison `json:"baseline,omitempty"`
 }
 
 func NewMetricCheckAction() action_kit_sdk.Action[MetricCheckState] {
@@ -42,6 +55,7 @@ func NewMetricCheckAction() action_kit_sdk.Action[MetricCheckState] {
 // Make sure PrometheusAction implements all required interfaces
 var _ action_kit_sdk.Action[MetricCheckState] = (*MetricCheckAction)(nil)
 var _ action_kit_sdk.ActionWithMetricQuery[MetricCheckState] = (*MetricCheckAction)(nil)
+var _ action_kit_sdk.ActionWithStatus[MetricCheckState] = (*MetricCheckAction)(nil)
 
 func (f MetricCheckAction) NewEmptyState() MetricCheckState {
 	return MetricCheckState{}
@@ -68,7 +82,7 @@ func (f MetricCheckAction) Describe() action_kit_api.ActionDescription {
 			}),
 		}),
 		Kind:        action_kit_api.Check,
-		TimeControl: action_kit_api.TimeControlExternal,
+		TimeControl: action_kit_api.TimeControlInternal,
 		Parameters: []action_kit_api.ActionParameter{
 			{
 				Label:        "Duration",
@@ -77,10 +91,135 @@ func (f MetricCheckAction) Describe() action_kit_api.ActionDescription {
 				Advanced:     new(false),
 				Required:     new(true),
 				DefaultValue: new("30s"),
+				Order:        new(0),
+			},
+			{
+				Label:       "Assertion PromQL Expression",
+				Name:        "expression",
+				Description: new("PromQL expression which is evaluated every second during the check. Leave empty to only gather metrics."),
+				Type:        action_kit_api.ActionParameterTypeString,
+				Required:    new(false),
+				Order:       new(1),
+			},
+			{
+				Label:        "Operator",
+				Name:         "operator",
+				Description:  new("How the result of the expression is compared against the threshold."),
+				Type:         action_kit_api.ActionParameterTypeString,
+				Required:     new(false),
+				DefaultValue: new(operatorLess),
+				Options:      new(operatorOptions()),
+				Order:        new(2),
+			},
+			{
+				Label:        "Threshold",
+				Name:         "threshold",
+				Description:  new("The expression result is compared against this number, e.g. 0.01. Not used when comparing against a baseline."),
+				Type:         action_kit_api.ActionParameterTypeString,
+				Required:     new(false),
+				DefaultValue: new("0"),
+				Order:        new(3),
+			},
+			extcheck.ModeParameter("When must the condition be met?", 4),
+			{
+				Label:        "Series Aggregation",
+				Name:         "seriesAggregation",
+				Description:  new("How many of the series returned by the expression must meet the condition?"),
+				Type:         action_kit_api.ActionParameterTypeString,
+				Required:     new(false),
+				Advanced:     new(true),
+				DefaultValue: new(seriesAggregationAll),
+				Options: new([]action_kit_api.ParameterOption{
+					action_kit_api.ExplicitParameterOption{Label: "All series", Value: seriesAggregationAll},
+					action_kit_api.ExplicitParameterOption{Label: "Any series", Value: seriesAggregationAny},
+					action_kit_api.ExplicitParameterOption{Label: "Minimum number of series", Value: seriesAggregationAtLeastCount},
+					action_kit_api.ExplicitParameterOption{Label: "Minimum percentage of series", Value: seriesAggregationAtLeastPercentage},
+				}),
+				Order: new(5),
+			},
+			{
+				Label:       "Series Minimum",
+				Name:        "seriesMinimum",
+				Description: new("Required series meeting the condition: the number of series for \"Minimum number of series\", defaulting to 1, or the percentage from 1 to 100 for \"Minimum percentage of series\", defaulting to 100."),
+				Type:        action_kit_api.ActionParameterTypeInteger,
+				Required:    new(false),
+				Advanced:    new(true),
+				MinValue:    new(1),
+				Order:       new(6),
+			},
+			{
+				Label:       "Tenant",
+				Name:        "tenant",
+				Description: new("Query this tenant instead of the one configured for the instance, e.g., a Grafana Mimir organization."),
+				Type:        action_kit_api.ActionParameterTypeString,
+				Required:    new(false),
+				Advanced:    new(true),
+				Order:       new(7),
+			},
+			{
+				Label:        "Compare To",
+				Name:         "comparison",
+				Description:  new("Compare the expression against the threshold or against its own average before the check, e.g., to fail if the latency degrades by more than 20%."),
+				Type:         action_kit_api.ActionParameterTypeString,
+				Required:     new(false),
+				Advanced:     new(true),
+				DefaultValue: new(comparisonThreshold),
+				Options: new([]action_kit_api.ParameterOption{
+					action_kit_api.ExplicitParameterOption{Label: "Threshold", Value: comparisonThreshold},
+					action_kit_api.ExplicitParameterOption{Label: "Baseline before the check", Value: comparisonBaseline},
+				}),
+				Order: new(8),
+			},
+			{
+				Label:        "Baseline Window",
+				Name:         "baselineWindow",
+				Description:  new("Duration before the check the baseline is averaged over."),
+				Type:         action_kit_api.ActionParameterTypeDuration,
+				Required:     new(false),
+				Advanced:     new(true),
+				DefaultValue: new("5m"),
+				Order:        new(9),
+			},
+			{
+				Label:        "Tolerance",
+				Name:         "tolerance",
+				Description:  new("How far the expression may deviate from its baseline in the direction of the operator, e.g., 20 for a value at most 20% above the baseline with '<='."),
+				Type:         action_kit_api.ActionParameterTypeString,
+				Required:     new(false),
+				Advanced:     new(true),
+				DefaultValue: new("20"),
+				Order:        new(10),
+			},
+			{
+				Label:        "Tolerance Type",
+				Name:         "toleranceType",
+				Description:  new("Whether the tolerance is a percentage of the baseline or an absolute value."),
+				Type:         action_kit_api.ActionParameterTypeString,
+				Required:     new(false),
+				Advanced:     new(true),
+				DefaultValue: new(toleranceRelative),
+				Options: new([]action_kit_api.ParameterOption{
+					action_kit_api.ExplicitParameterOption{Label: "Percent", Value: toleranceRelative},
+					action_kit_api.ExplicitParameterOption{Label: "Absolute", Value: toleranceAbsolute},
+				}),
+				Order: new(11),
+			},
+			{
+				Label:        "Dry Run",
+				Name:         "dryRun",
+				Description:  new("Evaluate the expression once while preparing the experiment and fail before any attack starts if the instance can't execute it."),
+				Type:         action_kit_api.ActionParameterTypeBoolean,
+				Required:     new(false),
+				Advanced:     new(true),
+				DefaultValue: new("false"),
+				Order:        new(12),
 			},
 		},
 		Prepare: action_kit_api.MutatingEndpointReference{},
 		Start:   action_kit_api.MutatingEndpointReference{},
+		Status: new(action_kit_api.MutatingEndpointReferenceWithCallInterval{
+			CallInterval: new("1s"),
+		}),
 		Metrics: new(action_kit_api.MetricsConfiguration{
 			Query: new(action_kit_api.MetricsQueryConfiguration{
 				Endpoint: action_kit_api.MutatingEndpointReferenceWithCallInterval{
@@ -93,25 +232,300 @@ func (f MetricCheckAction) Describe() action_kit_api.ActionDescription {
 						Required: new(true),
 						Type:     action_kit_api.ActionParameterTypeString,
 					},
+					{
+						Name:     "tenant",
+						Label:    "Tenant",
+						Required: new(false),
+						Type:     action_kit_api.ActionParameterTypeString,
+					},
+					{
+						Name:         "mode",
+						Label:        "Query Mode",
+						Description:  new("Range queries return all samples within the window, instant queries only the current value."),
+						Required:     new(false),
+						Type:         action_kit_api.ActionParameterTypeString,
+						DefaultValue: new(queryModeRange),
+						Options: new([]action_kit_api.ParameterOption{
+							action_kit_api.ExplicitParameterOption{Label: "Range", Value: queryModeRange},
+							action_kit_api.ExplicitParameterOption{Label: "Instant", Value: queryModeInstant},
+						}),
+					},
+					{
+						Name:         "window",
+						Label:        "Lookback Window",
+						Description:  new("Time range queried on each poll. Samples already returned by a previous poll are skipped, so the window should cover at least one scrape interval."),
+						Required:     new(false),
+						Type:         action_kit_api.ActionParameterTypeDuration,
+						DefaultValue: new("1m"),
+					},
+					{
+						Name:         "step",
+						Label:        "Step",
+						Description:  new("Resolution of range queries."),
+						Required:     new(false),
+						Type:         action_kit_api.ActionParameterTypeDuration,
+						DefaultValue: new("1s"),
+					},
+					{
+						Name:         "offset",
+						Label:        "Offset",
+						Description:  new("Shifts the queried time into the past to account for the ingestion delay, e.g., of remote storage."),
+						Required:     new(false),
+						Type:         action_kit_api.ActionParameterTypeDuration,
+						DefaultValue: new("0s"),
+					},
 				},
 			}),
 		}),
 	}
 }
 
-func (f MetricCheckAction) Prepare(_ context.Context, _ *MetricCheckState, _ action_kit_api.PrepareActionRequestBody) (*action_kit_api.PrepareResult, error) {
+func operatorOptions() []action_kit_api.ParameterOption {
+	options := make([]action_kit_api.ParameterOption, len(operators))
+	for i, operator := range operators {
+		options[i] = action_kit_api.ExplicitParameterOption{Label: operator, Value: operator}
+	}
+	return options
+}
+
+func (f MetricCheckAction) Prepare(ctx context.Context, state *MetricCheckState, request action_kit_api.PrepareActionRequestBody) (*action_kit_api.PrepareResult, error) {
+	if request.Target == nil {
+		return nil, new(extension_kit.ToError("No Prometheus instance selected", nil))
+	}
+	instance, err := extinstance.FindInstanceByName(request.Target.Name)
+	if err != nil {
+		return nil, new(extension_kit.ToError(fmt.Sprintf("Failed to find Prometheus instance named '%s'", request.Target.Name), err))
+	}
+
+	state.ExecutionId = request.ExecutionId
+	state.TargetName = request.Target.Name
+	state.Duration = extutil.ToInt64(request.Config["duration"])
+	state.Expression = strings.TrimSpace(extutil.ToString(request.Config["expression"]))
+	state.Tenant = strings.TrimSpace(extutil.ToString(request.Config["tenant"]))
+
+	variables := newTemplateVariables(request.ExecutionId, request.ExecutionContext, request.Properties, request.Target)
+
+	if state.Expression != "" {
+		state.Expression, err = renderQuery(state.Expression, variables)
+		if err != nil {
+			return nil, new(extension_kit.ToError("Invalid placeholder in PromQL expression", err))
+		}
+
+		c, err := parseCondition(extutil.ToString(request.Config["operator"]), extutil.ToString(request.Config["threshold"]))
+		if err != nil {
+			return nil, new(extension_kit.ToError("Invalid metric check condition", err))
+		}
+		state.Condition = c
+
+		baseline, err := parseBaselineComparison(extutil.ToString(request.Config["comparison"]), extutil.ToInt64(request.Config["baselineWindow"]),
+			extutil.ToString(request.Config["tolerance"]), extutil.ToString(request.Config["toleranceType"]))
+		if err != nil {
+			return nil, new(extension_kit.ToError("Invalid metric check baseline comparison", err))
+		}
+		state.Baseline = baseline
+
+		var seriesMinimum *int
+		if value := request.Config["seriesMinimum"]; value != nil && value != "" {
+			seriesMinimum = new(extutil.ToInt(value))
+		}
+		series, err := parseSeriesRequirement(extutil.ToString(request.Config["seriesAggregation"]), seriesMinimum)
+		if err != nil {
+			return nil, new(extension_kit.ToError("Invalid metric check series aggregation", err))
+		}
+		state.Series = series
+
+		state.CheckMode, err = extcheck.ParseMode(extutil.ToString(request.Config["checkMode"]))
+		if err != nil {
+			return nil, new(extension_kit.ToError("Invalid check mode", err))
+		}
+
+		if err := validateQuery(state.Expression, instance, request.Target); err != nil {
+			return nil, new(extension_kit.ToError(fmt.Sprintf("Invalid PromQL expression '%s'", state.Expression), err))
+		}
+		if extutil.ToBool(request.Config["dryRun"]) {
+			if state.Tenant != "" {
+				instance = instance.WithTenant(state.Tenant)
+			}
+			client, err := instance.GetApiClient()
+			if err != nil {
+				return nil, new(extension_kit.ToError("Failed to initialize Prometheus API client", err))
+			}
+			if _, err := queryInstant(ctx, instance, client, state.Expression, time.Now()); err != nil {
+				return nil, new(extension_kit.ToError(fmt.Sprintf("Dry run of '%s' against instance '%s' failed", state.Expression, request.Target.Name), err))
+			}
+		}
+	}
 	return nil, nil
 }
 
-func (f MetricCheckAction) Start(_ context.Context, _ *MetricCheckState) (*action_kit_api.StartResult, error) {
+func (f MetricCheckAction) Start(ctx context.Context, state *MetricCheckState) (*action_kit_api.StartResult, error) {
+	now := time.Now()
+	state.End = now.Add(time.Duration(state.Duration) * time.Millisecond)
+
+	if state.Expression != "" && state.Baseline != nil {
+		instance, client, err := getApiClient(state.TargetName, state.Tenant)
+		if err != nil {
+			return nil, err
+		}
+		baselines, err := queryBaseline(ctx, instance, client, state.Expression, time.Duration(state.Baseline.Window)*time.Millisecond, now)
+		if err != nil {
+			return nil, new(extension_kit.ToError(fmt.Sprintf("Failed to record the baseline of '%s' against instance '%s'", state.Expression, state.TargetName), err))
+		}
+		if len(baselines) == 0 {
+			return nil, new(extension_kit.ToError(fmt.Sprintf("Expression '%s' returned no data for the baseline window of %s before the check", state.Expression, time.Duration(state.Baseline.Window)*time.Millisecond), nil))
+		}
+		state.Baseline.Values = baselines
+	}
 	return nil, nil
 }
 
+func (f MetricCheckAction) Status(ctx context.Context, state *MetricCheckState) (*action_kit_api.StatusResult, error) {
+	now := time.Now()
+	completed := !now.Before(state.End)
+
+	if state.Expression == "" {
+		return &action_kit_api.StatusResult{Completed: completed}, nil
+	}
+	// Only the final evaluation decides the verdict, so there is no need to query before.
+	if extcheck.SkipEvaluation(state.CheckMode, completed) {
+		return &action_kit_api.StatusResult{Completed: false}, nil
+	}
+
+	samples, violation, err := evaluateCondition(ctx, state, now)
+	if err != nil {
+		return nil, err
+	}
+	if violation == "" {
+		state.ConditionMet = true
+	} else {
+		state.LastViolation = violation
+	}
+
+	result := extcheck.Verdict("Metric check failed", state.CheckMode, completed, violation, state.ConditionMet,
+		fmt.Sprintf("Condition was not met once during the check. Last evaluation: %s", state.LastViolation))
+	// The evaluated series are reported as metrics of the step. Unlike the metrics queries, the expression was rendered
+	// during the preparation, so its placeholders are resolved from the execution's properties on every replica.
+	if len(samples) > 0 {
+		result.Metrics = new(toMetrics(toMatrix(samples), state.Expression))
+	}
+	return result, nil
+}
+
+// evaluateCondition runs the state's expression against the instance and returns the samples and a description of the
+// violation, or an empty string if enough of the returned series satisfy the condition.
+func evaluateCondition(ctx context.Context, state *MetricCheckState, now time.Time) (samples model.Vector, violation string, err error) {
+	ctx, span := exttracing.Tracer().Start(ctx, "EvaluateCondition", trace.WithAttributes(
+		exttracing.ExecutionIdKey.String(state.ExecutionId.String()),
+		exttracing.InstanceKey.String(state.TargetName),
+		exttracing.QueryKey.String(state.Expression),
+	))
+	defer func() {
+		span.SetAttributes(attribute.String("steadybit.check.violation", violation))
+		exttracing.End(span, err)
+	}()
+
+	instance, client, err := getApiClient(state.TargetName, state.Tenant)
+	if err != nil {
+		return nil, "", err
+	}
+
+	samples, err = queryInstant(ctx, instance, client, state.Expression, now)
+	if err != nil {
+		return nil, "", new(extension_kit.ToError(fmt.Sprintf("Failed to evaluate '%s' against instance '%s'", state.Expression, state.TargetName), err))
+	}
+
+	expected := state.Condition.String()
+	if state.Baseline != nil {
+		expected = state.Baseline.describe(state.Condition.Operator)
+	}
+	if len(samples) == 0 {
+		return samples, fmt.Sprintf("Expression '%s' returned no data, expected %s.", state.Expression, expected), nil
+	}
+
+	if state.Baseline != nil {
+		met, failing := evaluateBaseline(samples, state.Condition.Operator, state.Baseline, state.Series)
+		if met {
+			return samples, "", nil
+		}
+		return samples, fmt.Sprintf("Expression '%s' met %s for %d of %d series, expected %s. Failing series: %s",
+			state.Expression, expected, len(samples)-len(failing), len(samples), state.Series, describeBaselineSeries(failing, state.Baseline)), nil
+	}
+
+	met, failing := evaluateSamples(samples, state.Condition, state.Series)
+	if met {
+		return samples, "", nil
+	}
+	return samples, fmt.Sprintf("Expression '%s' met %s for %d of %d series, expected %s. Failing series: %s",
+		state.Expression, state.Condition, len(samples)-len(failing), len(samples), state.Series, describeSeries(failing)), nil
+}
+
+// getApiClient returns the named instance, with the tenant of the check if set, and its API client.
+func getApiClient(targetName string, tenant string) (*extinstance.Instance, v1.API, error) {
+	instance, err := extinstance.FindInstanceByName(targetName)
+	if err != nil {
+		return nil, nil, new(extension_kit.ToError(fmt.Sprintf("Failed to find Prometheus instance named '%s'", targetName), err))
+	}
+	if tenant != "" {
+		instance = instance.WithTenant(tenant)
+	}
+
+	client, err := instance.GetApiClient()
+	if err != nil {
+		return nil, nil, new(extension_kit.ToError("Failed to initialize Prometheus API client", err))
+	}
+	return instance, client, nil
+}
+
+// queryInstant evaluates the query at the given time and returns the resulting samples. Scalar results are
+// returned as a single sample without labels.
+func queryInstant(ctx context.Context, instance *extinstance.Instance, client v1.API, query string, ts time.Time) (model.Vector, error) {
+	var result model.Value
+	err := instance.Retry(ctx, func(ctx context.Context) error {
+		value, warnings, err := client.Query(ctx, query, ts)
+		if err != nil {
+			return err
+		}
+		if len(warnings) > 0 {
+			log.Info().Str("query", query).Strs("warnings", warnings).Msg("Warnings returned from query.")
+		}
+
+		result = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	instance.RecordQueryResult(result)
+
+	switch value := result.(type) {
+	case model.Vector:
+		return value, nil
+	case *model.Scalar:
+		return model.Vector{{Metric: model.Metric{}, Value: value.Value, Timestamp: value.Timestamp}}, nil
+	default:
+		return nil, fmt.Errorf("expected vector or scalar as query result, but got %s", result.Type())
+	}
+}
+
 func (f MetricCheckAction) QueryMetrics(ctx context.Context, request action_kit_api.QueryMetricsRequestBody) (*action_kit_api.QueryMetricsResult, error) {
+	ctx, span := exttracing.Tracer().Start(ctx, "QueryMetrics", trace.WithAttributes(
+		exttracing.ExecutionIdKey.String(request.ExecutionId.String()),
+		exttracing.InstanceKey.String(request.Target.Name),
+		exttracing.QueryKey.String(extutil.ToString(request.Config["query"])),
+	))
+	result, err := queryMetrics(ctx, request)
+	exttracing.End(span, err)
+	return result, err
+}
+
+func queryMetrics(ctx context.Context, request action_kit_api.QueryMetricsRequestBody) (*action_kit_api.QueryMetricsResult, error) {
 	instance, err := extinstance.FindInstanceByName(request.Target.Name)
 	if err != nil {
 		return nil, new(extension_kit.ToError(fmt.Sprintf("Failed to find Prometheus instance named '%s'", request.Target.Name), err))
 	}
+	if tenant := strings.TrimSpace(extutil.ToString(request.Config["tenant"])); tenant != "" {
+		instance = instance.WithTenant(tenant)
+	}
 
 	client, err := instance.GetApiClient()
 	if err != nil {
@@ -126,49 +540,98 @@ func (f MetricCheckAction) QueryMetrics(ctx context.Context, request action_kit_
 	if !ok {
 		return nil, new(extension_kit.ToError("PromQL query must be a string", nil))
 	}
+	// Metrics queries don't receive the properties of the execution, so only its id and the target's attributes are known.
+	query, err = renderQuery(query, newTemplateVariables(request.ExecutionId, nil, nil, request.Target))
+	if err != nil {
+		return nil, new(extension_kit.ToError("Invalid placeholder in PromQL query", err))
+	}
+	if err := validateQuery(query, instance, request.Target); err != nil {
+		return nil, new(extension_kit.ToError(fmt.Sprintf("Invalid PromQL query '%s'", query), err))
+	}
 
-	retries := config.Config.QueryRetries
-
-	// Use QueryRange instead of Query to get actual metric timestamps
-	start := request.Timestamp.Add(-time.Duration(1) * time.Second) // Adjust start time to ensure we capture the last second of data, matching the call interval
-	end := request.Timestamp
-	step := 1 * time.Second
-
-	r := v1.Range{
-		Start: start,
-		End:   end,
-		Step:  step,
+	window, err := parseQueryWindow(request.Config)
+	if err != nil {
+		return nil, new(extension_kit.ToError("Invalid metrics query configuration", err))
 	}
 
-	var result model.Value
-	err = retry.Do(ctx, retry.WithMaxRetries(uint64(retries), retry.NewFibonacci(50*time.Millisecond)), func(ctx context.Context) error {
-		value, warnings, err := client.QueryRange(ctx, query, r)
+	var matrix model.Matrix
+	if window.Mode == queryModeInstant {
+		ts := request.Timestamp.Add(-window.Offset)
+		samples, err := queryInstant(ctx, instance, client, query, ts)
 		if err != nil {
-			return retry.RetryableError(err)
+			return nil, new(extension_kit.ToError(fmt.Sprintf("Failed to execute Prometheus instant query against instance '%s' at %s with query '%s'",
+				request.Target.Name,
+				ts,
+				query),
+				err))
 		}
-		if len(warnings) > 0 {
-			log.Info().Str("query", query).Strs("warnings", warnings).Msg("Warnings returned from query.")
+		matrix = toMatrix(samples)
+	} else {
+		// Use QueryRange instead of Query to get actual metric timestamps
+		start, end := window.rangeAt(request.Timestamp)
+		r := v1.Range{
+			Start: start,
+			End:   end,
+			Step:  window.Step,
 		}
 
-		result = value
-		return nil
-	})
-	if err != nil {
-		return nil, new(extension_kit.ToError(fmt.Sprintf("Failed to execute Prometheus range query against instance '%s' from %s to %s with query '%s'",
-			request.Target.Name,
-			start,
-			end,
-			query),
-			err))
+		var result model.Value
+		err = instance.Retry(ctx, func(ctx context.Context) error {
+			value, warnings, err := client.QueryRange(ctx, query, r)
+			if err != nil {
+				return err
+			}
+			if len(warnings) > 0 {
+				log.Info().Str("query", query).Strs("warnings", warnings).Msg("Warnings returned from query.")
+			}
+
+			result = value
+			return nil
+		})
+		if err != nil {
+			return nil, new(extension_kit.ToError(fmt.Sprintf("Failed to execute Prometheus range query against instance '%s' from %s to %s with query '%s'",
+				request.Target.Name,
+				start,
+				end,
+				query),
+				err))
+		}
+		instance.RecordQueryResult(result)
+
+		// QueryRange returns a matrix instead of a vector
+		matrix, ok = result.(model.Matrix)
+		if !ok {
+			return nil, new(extension_kit.ToError("PromQL range query returned unexpected result. Expected matrix type as query result", nil))
+		}
 	}
 
-	// QueryRange returns a matrix instead of a vector
-	matrix, ok := result.(model.Matrix)
-	if !ok {
-		return nil, new(extension_kit.ToError("PromQL range query returned unexpected result. Expected matrix type as query result", nil))
+	// The windows of subsequent polls overlap, so only samples which weren't returned before are reported.
+	matrix = dropSeenSamples(seenSamplesKey{
+		executionId: request.ExecutionId,
+		target:      request.Target.Name,
+		tenant:      instance.Tenant,
+		query:       query,
+	}, matrix, time.Now())
+
+	return new(action_kit_api.QueryMetricsResult{
+		Metrics: new(toMetrics(matrix, query)),
+	}), nil
+}
+
+// toMatrix turns the samples of an instant query into series with a single sample each.
+func toMatrix(samples model.Vector) model.Matrix {
+	matrix := make(model.Matrix, 0, len(samples))
+	for _, sample := range samples {
+		matrix = append(matrix, &model.SampleStream{
+			Metric: sample.Metric,
+			Values: []model.SamplePair{{Timestamp: sample.Timestamp, Value: sample.Value}},
+		})
 	}
+	return matrix
+}
 
-	// Process the matrix result
+// toMetrics converts the series returned for a query into metrics of the step.
+func toMetrics(matrix model.Matrix, query string) []action_kit_api.Metric {
 	var metrics []action_kit_api.Metric
 	for _, sampleStream := range matrix {
 		// For each time series in the matrix
@@ -192,10 +655,7 @@ func (f MetricCheckAction) QueryMetrics(ctx context.Context, request action_kit_
 			metrics = append(metrics, metric)
 		}
 	}
-
-	return new(action_kit_api.QueryMetricsResult{
-		Metrics: new(metrics),
-	}), nil
+	return metrics
 }
 
 type Metric struct {
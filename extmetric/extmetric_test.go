@@ -13,10 +13,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	dcontainer "github.com/moby/moby/api/types/container"
 	"github.com/steadybit/action-kit/go/action_kit_api/v2"
 	"github.com/steadybit/action-kit/go/action_kit_sdk"
 	"github.com/steadybit/extension-prometheus/v2/config"
+	"github.com/steadybit/extension-prometheus/v2/extcheck"
 	"github.com/steadybit/extension-prometheus/v2/extinstance"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -30,7 +32,7 @@ func TestQueryMetrics(t *testing.T) {
 	require.Nil(t, err)
 
 	instance := extinstance.Instance{Name: "test-prom", BaseUrl: container.baseUrl}
-	extinstance.Instances = []extinstance.Instance{instance}
+	extinstance.SetInstances([]extinstance.Instance{instance})
 
 	require.Eventually(t, func() bool {
 		result, err := getTestMetric(instance)
@@ -79,7 +81,7 @@ func TestQueryRetries(t *testing.T) {
 			})
 			config.Config.QueryRetries = tt.retries
 			instance := extinstance.Instance{Name: "flaky-prom", BaseUrl: flakyPrometheusURL}
-			extinstance.Instances = []extinstance.Instance{instance}
+			extinstance.SetInstances([]extinstance.Instance{instance})
 
 			_, err := getTestMetric(instance)
 
@@ -92,6 +94,39 @@ func TestQueryRetries(t *testing.T) {
 	}
 }
 
+func TestQueryRetries_PerInstance(t *testing.T) {
+	prevRetries := config.Config.QueryRetries
+	t.Cleanup(func() {
+		config.Config.QueryRetries = prevRetries
+	})
+	config.Config.QueryRetries = 0
+
+	instance := extinstance.Instance{Name: "flaky-prom", BaseUrl: setupFlakyInstance(t), QueryRetries: new(1)}
+	extinstance.SetInstances([]extinstance.Instance{instance})
+
+	_, err := getTestMetric(instance)
+	require.Nil(t, err)
+}
+
+func TestQueryTimeout_PerInstance(t *testing.T) {
+	prevTimeout := config.Config.RequestTimeout
+	t.Cleanup(func() {
+		config.Config.RequestTimeout = prevTimeout
+	})
+	config.Config.RequestTimeout = 2 * time.Second
+
+	instance := extinstance.Instance{
+		Name:           "slow-prom",
+		BaseUrl:        setupSlowInstance(t, 500*time.Millisecond),
+		RequestTimeout: extinstance.Duration(100 * time.Millisecond),
+		QueryRetries:   new(0),
+	}
+	extinstance.SetInstances([]extinstance.Instance{instance})
+
+	_, err := getTestMetric(instance)
+	require.NotNil(t, err)
+}
+
 func TestQueryTimeout(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -126,7 +161,7 @@ func TestQueryTimeout(t *testing.T) {
 			config.Config.QueryRetries = 0
 
 			instance := extinstance.Instance{Name: "slow-prom", BaseUrl: slowPrometheusURL}
-			extinstance.Instances = []extinstance.Instance{instance}
+			extinstance.SetInstances([]extinstance.Instance{instance})
 
 			_, err := getTestMetric(instance)
 
@@ -139,17 +174,296 @@ func TestQueryTimeout(t *testing.T) {
 	}
 }
 
+func TestPrepare(t *testing.T) {
+	extinstance.SetInstances([]extinstance.Instance{{Name: "test-prom", BaseUrl: "http://localhost:9090"}})
+	action := NewMetricCheckAction()
+
+	t.Run("valid condition", func(t *testing.T) {
+		state := action.NewEmptyState()
+		_, err := action.Prepare(context.Background(), &state, prepareRequest("test-prom", map[string]any{
+			"duration":   float64(10000),
+			"expression": "up",
+			"operator":   ">=",
+			"threshold":  "1",
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, "test-prom", state.TargetName)
+		assert.Equal(t, int64(10000), state.Duration)
+		assert.Equal(t, "up", state.Expression)
+		assert.Equal(t, condition{Operator: ">=", Threshold: 1}, state.Condition)
+		assert.Equal(t, extcheck.ModeAllTheTime, state.CheckMode)
+		assert.Equal(t, seriesRequirement{Aggregation: seriesAggregationAll}, state.Series)
+	})
+
+	t.Run("invalid series minimum", func(t *testing.T) {
+		state := action.NewEmptyState()
+		_, err := action.Prepare(context.Background(), &state, prepareRequest("test-prom", map[string]any{
+			"expression":        "up",
+			"operator":          ">=",
+			"threshold":         "1",
+			"seriesAggregation": seriesAggregationAtLeastPercentage,
+			"seriesMinimum":     float64(120),
+		}))
+		assert.ErrorContains(t, err, "minimum percentage of series must be between 1 and 100")
+	})
+
+	t.Run("invalid check mode", func(t *testing.T) {
+		state := action.NewEmptyState()
+		_, err := action.Prepare(context.Background(), &state, prepareRequest("test-prom", map[string]any{
+			"expression": "up",
+			"operator":   ">=",
+			"threshold":  "1",
+			"checkMode":  "sometimes",
+		}))
+		assert.ErrorContains(t, err, "unsupported check mode 'sometimes'")
+	})
+
+	t.Run("invalid operator", func(t *testing.T) {
+		state := action.NewEmptyState()
+		_, err := action.Prepare(context.Background(), &state, prepareRequest("test-prom", map[string]any{
+			"duration":   float64(10000),
+			"expression": "up",
+			"operator":   "=~",
+			"threshold":  "1",
+		}))
+		assert.ErrorContains(t, err, "unsupported operator")
+	})
+
+	t.Run("unknown instance", func(t *testing.T) {
+		state := action.NewEmptyState()
+		_, err := action.Prepare(context.Background(), &state, prepareRequest("unknown", map[string]any{}))
+		assert.ErrorContains(t, err, "Failed to find Prometheus instance named 'unknown'")
+	})
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		result     string
+		condition  condition
+		series     seriesRequirement
+		wantFailed bool
+		wantDetail string
+	}{
+		{
+			name:      "condition met",
+			result:    `{"resultType":"vector","result":[{"metric":{"job":"a"},"value":[1675956970.123,"0.5"]},{"metric":{"job":"b"},"value":[1675956970.123,"0.2"]}]}`,
+			condition: condition{Operator: "<", Threshold: 1},
+		},
+		{
+			name:       "condition violated",
+			result:     `{"resultType":"vector","result":[{"metric":{"job":"a"},"value":[1675956970.123,"0.5"]},{"metric":{"job":"b"},"value":[1675956970.123,"3"]}]}`,
+			condition:  condition{Operator: "<", Threshold: 1},
+			wantFailed: true,
+			wantDetail: `Expression 'up' met < 1 for 1 of 2 series, expected all series. Failing series: {job="b"} = 3`,
+		},
+		{
+			name:      "any series met",
+			result:    `{"resultType":"vector","result":[{"metric":{"job":"a"},"value":[1675956970.123,"0.5"]},{"metric":{"job":"b"},"value":[1675956970.123,"3"]}]}`,
+			condition: condition{Operator: "<", Threshold: 1},
+			series:    seriesRequirement{Aggregation: seriesAggregationAny},
+		},
+		{
+			name:       "minimum percentage violated",
+			result:     `{"resultType":"vector","result":[{"metric":{"pod":"a"},"value":[1675956970.123,"0.5"]},{"metric":{"pod":"b"},"value":[1675956970.123,"3"]},{"metric":{"pod":"c"},"value":[1675956970.123,"4"]}]}`,
+			condition:  condition{Operator: "<", Threshold: 1},
+			series:     seriesRequirement{Aggregation: seriesAggregationAtLeastPercentage, Minimum: 50},
+			wantFailed: true,
+			wantDetail: `Expression 'up' met < 1 for 1 of 3 series, expected at least 50% of series. Failing series: {pod="b"} = 3, {pod="c"} = 4`,
+		},
+		{
+			name:      "scalar result",
+			result:    `{"resultType":"scalar","result":[1675956970.123,"2"]}`,
+			condition: condition{Operator: "==", Threshold: 2},
+		},
+		{
+			name:       "no data",
+			result:     `{"resultType":"vector","result":[]}`,
+			condition:  condition{Operator: "<", Threshold: 1},
+			wantFailed: true,
+			wantDetail: "Expression 'up' returned no data, expected < 1.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := setupQueryResultInstance(t, tt.result)
+			extinstance.SetInstances([]extinstance.Instance{{Name: "test-prom", BaseUrl: url}})
+			action := NewMetricCheckAction().(action_kit_sdk.ActionWithStatus[MetricCheckState])
+
+			state := MetricCheckState{
+				TargetName: "test-prom",
+				End:        time.Now().Add(time.Minute),
+				Expression: "up",
+				Condition:  tt.condition,
+				Series:     tt.series,
+				CheckMode:  extcheck.ModeAllTheTime,
+			}
+			result, err := action.Status(context.Background(), &state)
+			require.NoError(t, err)
+
+			if tt.wantFailed {
+				require.NotNil(t, result.Error)
+				assert.True(t, result.Completed)
+				assert.Equal(t, action_kit_api.Failed, *result.Error.Status)
+				assert.Equal(t, tt.wantDetail, *result.Error.Detail)
+				return
+			}
+			assert.Nil(t, result.Error)
+			assert.False(t, result.Completed)
+		})
+	}
+}
+
+func TestStatus_ReportsEvaluatedSeries(t *testing.T) {
+	url := setupQueryResultInstance(t, `{"resultType":"vector","result":[{"metric":{"job":"a"},"value":[1675956970.123,"0.5"]},{"metric":{"job":"b"},"value":[1675956970.123,"3"]}]}`)
+	extinstance.SetInstances([]extinstance.Instance{{Name: "test-prom", BaseUrl: url}})
+	action := NewMetricCheckAction().(action_kit_sdk.ActionWithStatus[MetricCheckState])
+
+	state := MetricCheckState{
+		TargetName: "test-prom",
+		End:        time.Now().Add(time.Minute),
+		Expression: `up{namespace="shop"}`,
+		Condition:  condition{Operator: "<", Threshold: 10},
+		CheckMode:  extcheck.ModeAllTheTime,
+	}
+	result, err := action.Status(context.Background(), &state)
+	require.NoError(t, err)
+	require.NotNil(t, result.Metrics)
+	require.Len(t, *result.Metrics, 2)
+	assert.Equal(t, map[string]string{"job": "a"}, (*result.Metrics)[0].Metric)
+	assert.Equal(t, 0.5, (*result.Metrics)[0].Value)
+	assert.Equal(t, time.UnixMilli(1675956970123).UTC(), (*result.Metrics)[0].Timestamp.UTC())
+	assert.Equal(t, float64(3), (*result.Metrics)[1].Value)
+}
+
+func TestStatus_CheckModes(t *testing.T) {
+	const met = `{"resultType":"vector","result":[{"metric":{},"value":[1675956970.123,"0"]}]}`
+	const violated = `{"resultType":"vector","result":[{"metric":{},"value":[1675956970.123,"5"]}]}`
+
+	tests := []struct {
+		name       string
+		checkMode  string
+		results    []string
+		wantFailed bool
+	}{
+		{name: "at least once met in between", checkMode: extcheck.ModeAtLeastOnce, results: []string{violated, met, violated}},
+		{name: "at least once never met", checkMode: extcheck.ModeAtLeastOnce, results: []string{violated, violated, violated}, wantFailed: true},
+		{name: "all the time violated once", checkMode: extcheck.ModeAllTheTime, results: []string{met, violated, met}, wantFailed: true},
+		{name: "at end met", checkMode: extcheck.ModeAtEnd, results: []string{violated, violated, met}},
+		{name: "at end violated", checkMode: extcheck.ModeAtEnd, results: []string{met, met, violated}, wantFailed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			current := ""
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = fmt.Fprintf(w, `{"status":"success","data":%s}`, current)
+			}))
+			t.Cleanup(server.Close)
+			extinstance.SetInstances([]extinstance.Instance{{Name: "test-prom", BaseUrl: server.URL}})
+			action := NewMetricCheckAction().(action_kit_sdk.ActionWithStatus[MetricCheckState])
+
+			state := MetricCheckState{
+				TargetName: "test-prom",
+				Expression: "up",
+				Condition:  condition{Operator: "<", Threshold: 1},
+				CheckMode:  tt.checkMode,
+			}
+
+			var result *action_kit_api.StatusResult
+			for i, r := range tt.results {
+				current = r
+				if i == len(tt.results)-1 {
+					state.End = time.Now().Add(-time.Second)
+				} else {
+					state.End = time.Now().Add(time.Minute)
+				}
+				var err error
+				result, err = action.Status(context.Background(), &state)
+				require.NoError(t, err)
+				if result.Completed {
+					break
+				}
+			}
+
+			if tt.checkMode == extcheck.ModeAtEnd {
+				assert.Equal(t, 1, requests, "only the final evaluation should query the instance")
+			}
+			assert.True(t, result.Completed)
+			if tt.wantFailed {
+				require.NotNil(t, result.Error)
+				assert.Equal(t, action_kit_api.Failed, *result.Error.Status)
+				return
+			}
+			assert.Nil(t, result.Error)
+		})
+	}
+}
+
+func TestStatus_TenantOverride(t *testing.T) {
+	var tenants []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenants = append(tenants, r.URL.Path+" "+r.Header.Get("X-Scope-OrgID"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1675956970.123,"1"]}]}}`)
+	}))
+	t.Cleanup(server.Close)
+	extinstance.SetInstances([]extinstance.Instance{{Name: "mimir", BaseUrl: server.URL, Tenant: "platform"}})
+	action := NewMetricCheckAction()
+
+	for _, tenant := range []string{"", "checkout"} {
+		state := action.NewEmptyState()
+		_, err := action.Prepare(context.Background(), &state, prepareRequest("mimir", map[string]any{
+			"expression": "up",
+			"operator":   "==",
+			"threshold":  "1",
+			"tenant":     tenant,
+		}))
+		require.NoError(t, err)
+		state.End = time.Now().Add(-time.Second)
+
+		result, err := action.(action_kit_sdk.ActionWithStatus[MetricCheckState]).Status(context.Background(), &state)
+		require.NoError(t, err)
+		assert.Nil(t, result.Error)
+	}
+	assert.Equal(t, []string{
+		"/api/v1/query platform", "/api/v1/query checkout"}, tenants)
+}
+
+func TestStatus_CompletesAfterDuration(t *testing.T) {
+	action := NewMetricCheckAction().(action_kit_sdk.ActionWithStatus[MetricCheckState])
+	state := MetricCheckState{End: time.Now().Add(-time.Second)}
+
+	result, err := action.Status(context.Background(), &state)
+	require.NoError(t, err)
+	assert.True(t, result.Completed)
+	assert.Nil(t, result.Error)
+}
+
+func prepareRequest(targetName string, config map[string]any) action_kit_api.PrepareActionRequestBody {
+	return action_kit_api.PrepareActionRequestBody{
+		Target: new(action_kit_api.Target{
+			Name: targetName,
+		}),
+		Config: config,
+	}
+}
+
 func getTestMetric(instance extinstance.Instance) (*action_kit_api.QueryMetricsResult, error) {
 	timestamp := time.Now()
 	action := NewMetricCheckAction().(action_kit_sdk.ActionWithMetricQuery[MetricCheckState])
 
 	return action.QueryMetrics(context.Background(), action_kit_api.QueryMetricsRequestBody{
+		ExecutionId: uuid.New(),
 		Target: new(action_kit_api.Target{
 			Name: instance.Name,
 		}),
 		Timestamp: timestamp,
 		Config: map[string]any{
-			"query": "up",
+			"query":  "up",
+			"window": float64(0),
 		},
 	})
 
@@ -280,3 +594,20 @@ func setupFlakyInstance(t *testing.T) (url string) {
 
 	return server.URL
 }
+
+func setupQueryResultInstance(t *testing.T, data string) (url string) {
+	t.Helper()
+
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			if _, err := fmt.Fprintf(w, `{"status":"success","data":%s}`, data); err != nil {
+				http.Error(w, "Failed to write response", http.StatusInternalServerError)
+			}
+		}),
+	)
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extmetric
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+	"github.com/steadybit/extension-prometheus/v2/extinstance"
+)
+
+func init() {
+	// Accept everything a Prometheus with all feature flags enabled accepts. Whether the instance actually supports an
+	// experimental feature is up to the dry run.
+	parser.EnableExperimentalFunctions = true
+	parser.ExperimentalDurationExpr = true
+	parser.EnableExtendedRangeSelectors = true
+}
+
+// queryError is a syntax error in a PromQL expression at a 1-based line and column.
+type queryError struct {
+	query  string
+	line   int
+	column int
+	msg    string
+}
+
+func (e *queryError) Error() string {
+	lines := strings.Split(e.query, "\n")
+	if e.line < 1 || e.line > len(lines) {
+		return fmt.Sprintf("%d:%d: %s", e.line, e.column, e.msg)
+	}
+	marker := strings.Repeat(" ", max(e.column-1, 0)) + "^"
+	return fmt.Sprintf("%d:%d: %s\n%s\n%s", e.line, e.column, e.msg, lines[e.line-1], marker)
+}
+
+// validateQuery checks the syntax of a PromQL expression with the parser of Prometheus, unless the instance the target
+// refers to has a different dialect, e.g., the MetricsQL of VictoriaMetrics.
+func validateQuery(query string, instance *extinstance.Instance, target *action_kit_api.Target) error {
+	var flavour string
+	if target != nil && len(target.Attributes[extinstance.FlavourAttribute]) > 0 {
+		flavour = target.Attributes[extinstance.FlavourAttribute][0]
+	}
+	if !instance.ValidatesQueries(flavour) {
+		return nil
+	}
+	_, err := parser.ParseExpr(query)
+	if err == nil {
+		return nil
+	}
+
+	var parseErrs parser.ParseErrors
+	if !errors.As(err, &parseErrs) || len(parseErrs) == 0 {
+		return err
+	}
+	line, column := queryPosition(query, int(parseErrs[0].PositionRange.Start))
+	return &queryError{query: query, line: line, column: column, msg: parseErrs[0].Err.Error()}
+}
+
+// queryPosition converts a 0-based byte offset into a 1-based line and column.
+func queryPosition(query string, offset int) (line int, column int) {
+	offset = min(max(offset, 0), len(query))
+	lineStart := strings.LastIndexByte(query[:offset], '\n') + 1
+	return strings.Count(query[:offset], "\n") + 1, offset - lineStart + 1
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extmetric
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+	"github.com/steadybit/extension-prometheus/v2/config"
+	"github.com/steadybit/extension-prometheus/v2/extinstance"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		wantErr string
+	}{
+		{query: `sum(rate(http_requests_total{job="api"}[5m])) by (code)`},
+		{query: `up{job=~"a(b)"}`},
+		{query: "up # comment with ( bracket\n+ up"},
+		{query: "label_replace(up, \"dst\", \"$1\", `src`, `(.*)`)"},
+		{query: "rate(x[5m]) typo", wantErr: "1:13: unexpected identifier \"typo\"\nrate(x[5m]) typo\n            ^"},
+		{query: `sum(rate(x[5m])`, wantErr: "unclosed left parenthesis"},
+		{query: `rate(x[5m)`, wantErr: "1:"},
+		{query: "sum(up)\n  by (job) foo", wantErr: "2:12: unexpected identifier \"foo\"\n  by (job) foo\n           ^"},
+		{query: `up{job="api}`, wantErr: "unterminated quoted string"},
+		{query: `up{job=~"a(b"}`, wantErr: "1:4: error parsing regexp: missing closing )"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			err := validateQuery(tt.query, &extinstance.Instance{Name: "prometheus"}, nil)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateQuery_Skipped(t *testing.T) {
+	prometheus := &extinstance.Instance{Name: "prometheus"}
+	victoriaMetrics := &action_kit_api.Target{Name: "victoria-metrics", Attributes: map[string][]string{
+		extinstance.FlavourAttribute: {extinstance.FlavourVictoriaMetrics},
+	}}
+
+	assert.Error(t, validateQuery("rate(x[5m]) typo", prometheus, nil))
+	assert.NoError(t, validateQuery("rate(x[5m]) typo", prometheus, victoriaMetrics))
+	assert.NoError(t, validateQuery("rate(x[5m]) typo", &extinstance.Instance{Name: "metricsql", SkipQueryValidation: new(true)}, nil))
+	assert.Error(t, validateQuery("rate(x[5m]) typo", &extinstance.Instance{Name: "strict", SkipQueryValidation: new(false)}, victoriaMetrics))
+
+	config.Config.SkipQueryValidation = true
+	t.Cleanup(func() { config.Config.SkipQueryValidation = false })
+	assert.NoError(t, validateQuery("rate(x[5m]) typo", prometheus, nil))
+}
+
+// newFailingQueryServer returns an instance rejecting every query.
+func newFailingQueryServer(t *testing.T) *extinstance.Instance {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = w.Write([]byte(`{"status":"error","errorType":"execution","error":"query processing would load too many samples"}`))
+	}))
+	t.Cleanup(server.Close)
+	return &extinstance.Instance{Name: "failing", BaseUrl: server.URL}
+}
+
+func TestPrepare_ValidatesExpression(t *testing.T) {
+	instance := newFailingQueryServer(t)
+	extinstance.SetInstances([]extinstance.Instance{*instance})
+	action := NewMetricCheckAction()
+
+	state := action.NewEmptyState()
+	_, err := action.Prepare(context.Background(), &state, prepareRequest(instance.Name, map[string]any{
+		"expression": "rate(x[5m]) typo",
+		"operator":   "<",
+		"threshold":  "1",
+	}))
+	assert.ErrorContains(t, err, "Invalid PromQL expression 'rate(x[5m]) typo'")
+	assert.ErrorContains(t, err, "1:13: unexpected identifier")
+
+	state = action.NewEmptyState()
+	_, err = action.Prepare(context.Background(), &state, prepareRequest(instance.Name, map[string]any{
+		"expression": "rate(x[5m])",
+		"operator":   "<",
+		"threshold":  "1",
+	}))
+	assert.NoError(t, err)
+
+	state = action.NewEmptyState()
+	_, err = action.Prepare(context.Background(), &state, prepareRequest(instance.Name, map[string]any{
+		"expression": "rate(x[5m])",
+		"operator":   "<",
+		"threshold":  "1",
+		"dryRun":     true,
+	}))
+	assert.ErrorContains(t, err, "Dry run of 'rate(x[5m])' against instance 'failing' failed")
+}
+
+func TestQueryMetrics_ValidatesQuery(t *testing.T) {
+	instance := newFailingQueryServer(t)
+	extinstance.SetInstances([]extinstance.Instance{*instance})
+
+	_, err := queryMetrics(context.Background(), action_kit_api.QueryMetricsRequestBody{
+		ExecutionId: uuid.New(),
+		Target:      new(action_kit_api.Target{Name: instance.Name}),
+		Config:      map[string]any{"query": "rate(x[5m]) typo"},
+		Timestamp:   time.Now(),
+	})
+	assert.ErrorContains(t, err, "Invalid PromQL query 'rate(x[5m]) typo'")
+	assert.ErrorContains(t, err, "1:13: unexpected identifier")
+}
@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extmetric
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	queryModeRange   = "range"
+	queryModeInstant = "instant"
+
+	defaultQueryWindow = time.Minute
+	defaultQueryStep   = time.Second
+	// maxQueryPoints is the maximum of points per series Prometheus returns for a range query.
+	maxQueryPoints = 11000
+	// seenSamplesRetention is how long the samples returned for an execution are remembered after its last poll.
+	seenSamplesRetention = 10 * time.Minute
+)
+
+// queryWindow describes which samples a metrics query fetches for a poll at a given timestamp.
+type queryWindow struct {
+	Mode   string
+	Window time.Duration
+	Step   time.Duration
+	// Offset shifts the queried time range into the past, e.g., to account for the ingestion delay of remote storage.
+	Offset time.Duration
+}
+
+func parseQueryWindow(config map[string]any) (queryWindow, error) {
+	w := queryWindow{Mode: queryModeRange}
+	if mode, ok := config["mode"].(string); ok && mode != "" {
+		w.Mode = mode
+	}
+	if w.Mode != queryModeRange && w.Mode != queryModeInstant {
+		return w, fmt.Errorf("unsupported query mode '%s', expected %s or %s", w.Mode, queryModeRange, queryModeInstant)
+	}
+
+	var err error
+	if w.Window, err = toDuration(config["window"], defaultQueryWindow); err != nil {
+		return w, fmt.Errorf("invalid window: %w", err)
+	}
+	if w.Step, err = toDuration(config["step"], defaultQueryStep); err != nil {
+		return w, fmt.Errorf("invalid step: %w", err)
+	}
+	if w.Offset, err = toDuration(config["offset"], 0); err != nil {
+		return w, fmt.Errorf("invalid offset: %w", err)
+	}
+
+	if w.Window < 0 || w.Offset < 0 {
+		return w, fmt.Errorf("window and offset must not be negative")
+	}
+	if w.Mode == queryModeRange {
+		if w.Step < time.Millisecond {
+			return w, fmt.Errorf("step must be at least 1ms")
+		}
+		if w.Window/w.Step >= maxQueryPoints {
+			return w, fmt.Errorf("window of %s with a step of %s exceeds the maximum of %d points per series", w.Window, w.Step, maxQueryPoints)
+		}
+	}
+	return w, nil
+}
+
+// rangeAt returns the time range to query for a poll at the given timestamp. Start and end are aligned to the step,
+// so subsequent polls evaluate the same timestamps and already returned samples can be skipped.
+func (w queryWindow) rangeAt(ts time.Time) (start time.Time, end time.Time) {
+	end = ts.Add(-w.Offset).Truncate(w.Step)
+	return end.Add(-w.Window), end
+}
+
+// toDuration converts a duration parameter, given in milliseconds or as Go duration string, e.g., "30s".
+func toDuration(value any, fallback time.Duration) (time.Duration, error) {
+	switch v := value.(type) {
+	case nil:
+		return fallback, nil
+	case float64:
+		return time.Duration(v * float64(time.Millisecond)), nil
+	case int:
+		return time.Duration(v) * time.Millisecond, nil
+	case int64:
+		return time.Duration(v) * time.Millisecond, nil
+	case string:
+		if v == "" {
+			return fallback, nil
+		}
+		if ms, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(ms * float64(time.Millisecond)), nil
+		}
+		return time.ParseDuration(v)
+	default:
+		return 0, fmt.Errorf("unsupported duration %v", value)
+	}
+}
+
+// seenSamplesKey identifies the metrics query of an execution.
+type seenSamplesKey struct {
+	executionId uuid.UUID
+	target      string
+	tenant      string
+	query       string
+}
+
+// seenSamples remembers the newest sample returned per series, so overlapping windows of subsequent polls don't
+// return samples again.
+type seenSamples struct {
+	lastPoll time.Time
+	newest   map[model.Fingerprint]model.Time
+}
+
+var (
+	seenSamplesMu sync.Mutex
+	seenByQuery   = map[seenSamplesKey]*seenSamples{}
+)
+
+// dropSeenSamples removes the samples already returned for the query of the execution and remembers the remaining
+// ones. Executions which weren't polled for a while are forgotten.
+func dropSeenSamples(key seenSamplesKey, matrix model.Matrix, now time.Time) model.Matrix {
+	seenSamplesMu.Lock()
+	defer seenSamplesMu.Unlock()
+
+	for k, seen := range seenByQuery {
+		if now.Sub(seen.lastPoll) > seenSamplesRetention {
+			delete(seenByQuery, k)
+		}
+	}
+	seen, ok := seenByQuery[key]
+	if !ok {
+		seen = &seenSamples{newest: map[model.Fingerprint]model.Time{}}
+		seenByQuery[key] = seen
+	}
+	seen.lastPoll = now
+
+	result := make(model.Matrix, 0, len(matrix))
+	for _, stream := range matrix {
+		fingerprint := stream.Metric.Fingerprint()
+		newest, known := seen.newest[fingerprint]
+		values := stream.Values[:0]
+		for _, pair := range stream.Values {
+			if !known || pair.Timestamp > newest {
+				values = append(values, pair)
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		seen.newest[fingerprint] = values[len(values)-1].Timestamp
+		stream.Values = values
+		result = append(result, stream)
+	}
+	return result
+}
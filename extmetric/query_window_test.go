@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extmetric
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/common/model"
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+	"github.com/steadybit/action-kit/go/action_kit_sdk"
+	"github.com/steadybit/extension-prometheus/v2/extinstance"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQueryWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]any
+		want    queryWindow
+		wantErr string
+	}{
+		{
+			name:   "defaults",
+			config: map[string]any{},
+			want:   queryWindow{Mode: queryModeRange, Window: time.Minute, Step: time.Second},
+		},
+		{
+			name:   "milliseconds",
+			config: map[string]any{"window": float64(300000), "step": float64(15000), "offset": float64(30000)},
+			want:   queryWindow{Mode: queryModeRange, Window: 5 * time.Minute, Step: 15 * time.Second, Offset: 30 * time.Second},
+		},
+		{
+			name:   "duration strings",
+			config: map[string]any{"mode": queryModeInstant, "window": "2m", "step": "30s", "offset": "1m"},
+			want:   queryWindow{Mode: queryModeInstant, Window: 2 * time.Minute, Step: 30 * time.Second, Offset: time.Minute},
+		},
+		{
+			name:    "unknown mode",
+			config:  map[string]any{"mode": "sometimes"},
+			wantErr: "unsupported query mode 'sometimes'",
+		},
+		{
+			name:    "zero step",
+			config:  map[string]any{"step": float64(0)},
+			wantErr: "step must be at least 1ms",
+		},
+		{
+			name:    "too many points",
+			config:  map[string]any{"window": "24h", "step": "1s"},
+			wantErr: "exceeds the maximum of 11000 points per series",
+		},
+		{
+			name:    "negative offset",
+			config:  map[string]any{"offset": float64(-1000)},
+			wantErr: "must not be negative",
+		},
+		{
+			name:    "invalid duration",
+			config:  map[string]any{"window": "a minute"},
+			wantErr: "invalid window",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseQueryWindow(tt.config)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestQueryWindow_RangeAt(t *testing.T) {
+	window := queryWindow{Mode: queryModeRange, Window: time.Minute, Step: 15 * time.Second, Offset: 30 * time.Second}
+	start, end := window.rangeAt(time.Date(2026, 1, 1, 12, 0, 52, 500, time.UTC))
+	assert.Equal(t, time.Date(2026, 1, 1, 12, 0, 15, 0, time.UTC), end)
+	assert.Equal(t, time.Date(2026, 1, 1, 11, 59, 15, 0, time.UTC), start)
+}
+
+func TestDropSeenSamples(t *testing.T) {
+	key := seenSamplesKey{executionId: uuid.New(), target: "prom", query: "up"}
+	series := func(job string, timestamps ...model.Time) *model.SampleStream {
+		stream := &model.SampleStream{Metric: model.Metric{"job": model.LabelValue(job)}}
+		for _, ts := range timestamps {
+			stream.Values = append(stream.Values, model.SamplePair{Timestamp: ts, Value: 1})
+		}
+		return stream
+	}
+	now := time.Now()
+
+	result := dropSeenSamples(key, model.Matrix{series("a", 1000, 2000), series("b", 1000)}, now)
+	assert.Equal(t, model.Matrix{series("a", 1000, 2000), series("b", 1000)}, result)
+
+	result = dropSeenSamples(key, model.Matrix{series("a", 1000, 2000, 3000), series("b", 1000)}, now)
+	assert.Equal(t, model.Matrix{series("a", 3000)}, result)
+
+	// Other executions are tracked separately
+	other := key
+	other.executionId = uuid.New()
+	result = dropSeenSamples(other, model.Matrix{series("a", 1000)}, now)
+	assert.Equal(t, model.Matrix{series("a", 1000)}, result)
+
+	// Executions which weren't polled for a while are forgotten
+	dropSeenSamples(other, nil, now.Add(seenSamplesRetention+time.Second))
+	assert.NotContains(t, seenByQuery, key)
+}
+
+func TestQueryMetrics_WindowAndMode(t *testing.T) {
+	var forms []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		forms = append(forms, r.Form)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/v1/query" {
+			_, _ = fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"job":"a"},"value":[%s,"1"]}]}}`, r.Form.Get("time"))
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"a"},"values":[[1767268800,"1"],[1767268815,"2"]]}]}}`)
+	}))
+	t.Cleanup(server.Close)
+	extinstance.SetInstances([]extinstance.Instance{{Name: "windowed", BaseUrl: server.URL}})
+	action := NewMetricCheckAction().(action_kit_sdk.ActionWithMetricQuery[MetricCheckState])
+
+	request := action_kit_api.QueryMetricsRequestBody{
+		ExecutionId: uuid.New(),
+		Target:      new(action_kit_api.Target{Name: "windowed"}),
+		Timestamp:   time.Date(2026, 1, 1, 12, 0, 52, 0, time.UTC),
+		Config:      map[string]any{"query": "up", "window": float64(60000), "step": float64(15000), "offset": float64(30000)},
+	}
+	result, err := action.QueryMetrics(context.Background(), request)
+	require.NoError(t, err)
+	assert.Len(t, *result.Metrics, 2)
+	assert.Equal(t, "1767268755", forms[0].Get("start"))
+	assert.Equal(t, "1767268815", forms[0].Get("end"))
+	assert.Equal(t, "15", forms[0].Get("step"))
+
+	// The next poll returns the same samples, which were already reported
+	result, err = action.QueryMetrics(context.Background(), request)
+	require.NoError(t, err)
+	assert.Empty(t, *result.Metrics)
+
+	request.Config["mode"] = queryModeInstant
+	result, err = action.QueryMetrics(context.Background(), request)
+	require.NoError(t, err)
+	require.Len(t, *result.Metrics, 1)
+	assert.Equal(t, "1767268822", forms[2].Get("time"))
+	assert.Equal(t, time.Date(2026, 1, 1, 12, 0, 22, 0, time.UTC), (*result.Metrics)[0].Timestamp.UTC())
+}
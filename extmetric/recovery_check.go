@@ -0,0 +1,288 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extmetric
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+	"github.com/steadybit/action-kit/go/action_kit_sdk"
+	extension_kit "github.com/steadybit/extension-kit"
+	"github.com/steadybit/extension-kit/extbuild"
+	"github.com/steadybit/extension-kit/extutil"
+	"github.com/steadybit/extension-prometheus/v2/extcheck"
+	"github.com/steadybit/extension-prometheus/v2/extinstance"
+	"github.com/steadybit/extension-prometheus/v2/exttracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recoveryTimeMetric is the name of the metric the measured time to recover is reported as.
+const recoveryTimeMetric = "recovery_time_seconds"
+
+type RecoveryTimeAction struct {
+}
+
+type RecoveryTimeState struct {
+	TargetName string    `json:"targetName"`
+	Tenant     string    `json:"tenant,omitempty"`
+	Expression string    `json:"expression"`
+	Condition  condition `json:"condition"`
+	// StableFor and MaxRecoveryTime are durations in milliseconds.
+	StableFor       int64     `json:"stableFor"`
+	MaxRecoveryTime int64     `json:"maxRecoveryTime"`
+	Start           time.Time `json:"start"`
+	// HoldingSince is the time since which the condition holds without interruption, nil if it currently doesn't.
+	HoldingSince *time.Time `json:"holdingSince,omitempty"`
+	// LastViolation describes the most recent evaluation which did not satisfy the condition.
+	LastViolation string `json:"lastViolation,omitempty"`
+}
+
+func NewRecoveryTimeAction() action_kit_sdk.Action[RecoveryTimeState] {
+	return RecoveryTimeAction{}
+}
+
+// Make sure RecoveryTimeAction implements all required interfaces
+var _ action_kit_sdk.Action[RecoveryTimeState] = (*RecoveryTimeAction)(nil)
+var _ action_kit_sdk.ActionWithStatus[RecoveryTimeState] = (*RecoveryTimeAction)(nil)
+
+func (f RecoveryTimeAction) NewEmptyState() RecoveryTimeState {
+	return RecoveryTimeState{}
+}
+
+func (f RecoveryTimeAction) Describe() action_kit_api.ActionDescription {
+	return action_kit_api.ActionDescription{
+		Id:          fmt.Sprintf("%s.recovery-time", extinstance.PrometheusInstanceTargetId),
+		Label:       "Prometheus recovery time",
+		Description: "Measure how long it takes until a PromQL condition holds again",
+		Version:     extbuild.GetSemverVersionStringOrUnknown(),
+		Icon:        extutil.Ptr(extinstance.PrometheusIcon),
+		Technology:  new("Prometheus"),
+
+		TargetSelection: new(action_kit_api.TargetSelection{
+			TargetType:          extinstance.PrometheusInstanceTargetId,
+			QuantityRestriction: extutil.Ptr(action_kit_api.QuantityRestrictionExactlyOne),
+			SelectionTemplates: new([]action_kit_api.TargetSelectionTemplate{
+				{
+					Label:       "instance-name",
+					Description: new("Find prometheus-instance by instance-name"),
+					Query:       "prometheus.instance.name=\"\"",
+				},
+			}),
+		}),
+		Kind:        action_kit_api.Check,
+		TimeControl: action_kit_api.TimeControlInternal,
+		Parameters: []action_kit_api.ActionParameter{
+			{
+				Label:       "Recovery PromQL Expression",
+				Name:        "expression",
+				Description: new("PromQL expression which is evaluated every second until it satisfies the condition, e.g., the error rate."),
+				Type:        action_kit_api.ActionParameterTypeString,
+				Required:    new(true),
+				Order:       new(0),
+			},
+			{
+				Label:        "Operator",
+				Name:         "operator",
+				Description:  new("How the result of the expression is compared against the threshold."),
+				Type:         action_kit_api.ActionParameterTypeString,
+				Required:     new(true),
+				DefaultValue: new(operatorLess),
+				Options:      new(operatorOptions()),
+				Order:        new(1),
+			},
+			{
+				Label:        "Threshold",
+				Name:         "threshold",
+				Description:  new("The expression result is compared against this number, e.g. 0.01."),
+				Type:         action_kit_api.ActionParameterTypeString,
+				Required:     new(true),
+				DefaultValue: new("0"),
+				Order:        new(2),
+			},
+			{
+				Label:        "Stable For",
+				Name:         "stableFor",
+				Description:  new("How long the condition must hold without interruption to consider the system recovered."),
+				Type:         action_kit_api.ActionParameterTypeDuration,
+				Required:     new(true),
+				DefaultValue: new("30s"),
+				Order:        new(3),
+			},
+			{
+				Label:        "Maximum Recovery Time",
+				Name:         "maxRecoveryTime",
+				Description:  new("The check fails if the condition doesn't start to hold within this duration."),
+				Type:         action_kit_api.ActionParameterTypeDuration,
+				Required:     new(true),
+				DefaultValue: new("5m"),
+				Order:        new(4),
+			},
+			{
+				Label:       "Tenant",
+				Name:        "tenant",
+				Description: new("Query this tenant instead of the one configured for the instance, e.g., a Grafana Mimir organization."),
+				Type:        action_kit_api.ActionParameterTypeString,
+				Required:    new(false),
+				Advanced:    new(true),
+				Order:       new(5),
+			},
+		},
+		Prepare: action_kit_api.MutatingEndpointReference{},
+		Start:   action_kit_api.MutatingEndpointReference{},
+		Status: new(action_kit_api.MutatingEndpointReferenceWithCallInterval{
+			CallInterval: new("1s"),
+		}),
+	}
+}
+
+func (f RecoveryTimeAction) Prepare(ctx context.Context, state *RecoveryTimeState, request action_kit_api.PrepareActionRequestBody) (*action_kit_api.PrepareResult, error) {
+	if request.Target == nil {
+		return nil, new(extension_kit.ToError("No Prometheus instance selected", nil))
+	}
+
+	state.TargetName = request.Target.Name
+	state.Tenant = strings.TrimSpace(extutil.ToString(request.Config["tenant"]))
+	instance, _, err := getApiClient(state.TargetName, state.Tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	state.Expression = strings.TrimSpace(extutil.ToString(request.Config["expression"]))
+	if state.Expression == "" {
+		return nil, new(extension_kit.ToError("No recovery PromQL expression defined", nil))
+	}
+	variables := newTemplateVariables(request.ExecutionId, request.ExecutionContext, request.Properties, request.Target)
+	state.Expression, err = renderQuery(state.Expression, variables)
+	if err != nil {
+		return nil, new(extension_kit.ToError("Invalid placeholder in PromQL expression", err))
+	}
+
+	state.Condition, err = parseCondition(extutil.ToString(request.Config["operator"]), extutil.ToString(request.Config["threshold"]))
+	if err != nil {
+		return nil, new(extension_kit.ToError("Invalid recovery condition", err))
+	}
+
+	state.StableFor = extutil.ToInt64(request.Config["stableFor"])
+	state.MaxRecoveryTime = extutil.ToInt64(request.Config["maxRecoveryTime"])
+	if state.StableFor < 0 || state.MaxRecoveryTime <= 0 {
+		return nil, new(extension_kit.ToError("Stable for must not be negative and the maximum recovery time must be positive", nil))
+	}
+
+	if err := validateQuery(state.Expression, instance, request.Target); err != nil {
+		return nil, new(extension_kit.ToError(fmt.Sprintf("Invalid PromQL expression '%s'", state.Expression), err))
+	}
+	return nil, nil
+}
+
+func (f RecoveryTimeAction) Start(_ context.Context, state *RecoveryTimeState) (*action_kit_api.StartResult, error) {
+	state.Start = time.Now()
+	return nil, nil
+}
+
+// Status evaluates the condition every call. The system is recovered once the condition held for StableFor, and the
+// time to recover is the time from the start of the step until the condition started to hold.
+func (f RecoveryTimeAction) Status(ctx context.Context, state *RecoveryTimeState) (*action_kit_api.StatusResult, error) {
+	now := time.Now()
+
+	violation, err := evaluateRecovery(ctx, state, now)
+	if err != nil {
+		return nil, err
+	}
+	if violation == "" {
+		if state.HoldingSince == nil {
+			state.HoldingSince = new(now)
+		}
+	} else {
+		state.HoldingSince = nil
+		state.LastViolation = violation
+	}
+
+	stableFor := time.Duration(state.StableFor) * time.Millisecond
+	maxRecoveryTime := time.Duration(state.MaxRecoveryTime) * time.Millisecond
+	if state.HoldingSince != nil && now.Sub(*state.HoldingSince) >= stableFor {
+		recoveryTime := state.HoldingSince.Sub(state.Start)
+		if recoveryTime > maxRecoveryTime {
+			return recoveryFailed(state, now, fmt.Sprintf("Recovered after %s, expected at most %s.", recoveryTime.Round(time.Second), maxRecoveryTime)), nil
+		}
+		return &action_kit_api.StatusResult{
+			Completed: true,
+			Messages: new([]action_kit_api.Message{
+				{
+					Level: new(action_kit_api.Info),
+					Message: fmt.Sprintf("Recovered after %s: expression '%s' met %s for %s.",
+						recoveryTime.Round(time.Second), state.Expression, state.Condition, stableFor),
+				},
+			}),
+			Metrics: new([]action_kit_api.Metric{newRecoveryTimeMetric(state, now)}),
+		}, nil
+	}
+
+	// Without the condition holding before the maximum recovery time, it can't be met anymore.
+	deadline := state.Start.Add(maxRecoveryTime)
+	if state.HoldingSince == nil && now.After(deadline) {
+		return recoveryFailed(state, now, fmt.Sprintf("Did not recover within %s. Last evaluation: %s", maxRecoveryTime, state.LastViolation)), nil
+	}
+	if state.HoldingSince != nil && state.HoldingSince.After(deadline) {
+		return recoveryFailed(state, now, fmt.Sprintf("Did not recover within %s, the condition only holds since %s after the start.",
+			maxRecoveryTime, state.HoldingSince.Sub(state.Start).Round(time.Second))), nil
+	}
+	return &action_kit_api.StatusResult{Completed: false}, nil
+}
+
+// recoveryFailed reports the time to recover as well if the condition holds by now, even if too late or not for long
+// enough yet.
+func recoveryFailed(state *RecoveryTimeState, now time.Time, detail string) *action_kit_api.StatusResult {
+	result := extcheck.Failed("Recovery time check failed", detail)
+	if state.HoldingSince != nil {
+		result.Metrics = new([]action_kit_api.Metric{newRecoveryTimeMetric(state, now)})
+	}
+	return result
+}
+
+// newRecoveryTimeMetric reports the time from the start of the step until the condition started to hold.
+func newRecoveryTimeMetric(state *RecoveryTimeState, now time.Time) action_kit_api.Metric {
+	return action_kit_api.Metric{
+		Name:      new(recoveryTimeMetric),
+		Metric:    map[string]string{"expression": state.Expression, "condition": state.Condition.String()},
+		Timestamp: now,
+		Value:     state.HoldingSince.Sub(state.Start).Seconds(),
+	}
+}
+
+// evaluateRecovery returns a description of the evaluation if the condition is not met by all series, or an empty
+// string otherwise.
+func evaluateRecovery(ctx context.Context, state *RecoveryTimeState, now time.Time) (violation string, err error) {
+	ctx, span := exttracing.Tracer().Start(ctx, "EvaluateRecovery", trace.WithAttributes(
+		exttracing.InstanceKey.String(state.TargetName),
+		exttracing.QueryKey.String(state.Expression),
+	))
+	defer func() {
+		span.SetAttributes(attribute.String("steadybit.check.violation", violation))
+		exttracing.End(span, err)
+	}()
+
+	instance, client, err := getApiClient(state.TargetName, state.Tenant)
+	if err != nil {
+		return "", err
+	}
+
+	samples, err := queryInstant(ctx, instance, client, state.Expression, now)
+	if err != nil {
+		return "", new(extension_kit.ToError(fmt.Sprintf("Failed to evaluate '%s' against instance '%s'", state.Expression, state.TargetName), err))
+	}
+	if len(samples) == 0 {
+		return fmt.Sprintf("Expression '%s' returned no data, expected %s.", state.Expression, state.Condition), nil
+	}
+
+	series := seriesRequirement{Aggregation: seriesAggregationAll}
+	if met, failing := evaluateSamples(samples, state.Condition, series); !met {
+		return fmt.Sprintf("Expression '%s' met %s for %d of %d series, expected %s. Failing series: %s",
+			state.Expression, state.Condition, len(samples)-len(failing), len(samples), series, describeSeries(failing)), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extmetric
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+	"github.com/steadybit/action-kit/go/action_kit_sdk"
+	"github.com/steadybit/extension-prometheus/v2/extinstance"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoveryTime(t *testing.T) {
+	errorRate := "0.2"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"service":"shop"},"value":[1767268800,"%s"]}]}}`, errorRate)
+	}))
+	t.Cleanup(server.Close)
+	extinstance.SetInstances([]extinstance.Instance{{Name: "recovery", BaseUrl: server.URL}})
+	action := NewRecoveryTimeAction()
+
+	prepare := func(t *testing.T) RecoveryTimeState {
+		state := action.NewEmptyState()
+		_, err := action.Prepare(context.Background(), &state, prepareRequest("recovery", map[string]any{
+			"expression":      "error_rate",
+			"operator":        "<",
+			"threshold":       "0.01",
+			"stableFor":       float64(30000),
+			"maxRecoveryTime": float64(300000),
+		}))
+		require.NoError(t, err)
+		_, err = action.Start(context.Background(), &state)
+		require.NoError(t, err)
+		return state
+	}
+	status := func(t *testing.T, state *RecoveryTimeState) *action_kit_api.StatusResult {
+		result, err := action.(action_kit_sdk.ActionWithStatus[RecoveryTimeState]).Status(context.Background(), state)
+		require.NoError(t, err)
+		return result
+	}
+
+	t.Run("recovers once the condition held long enough", func(t *testing.T) {
+		state := prepare(t)
+		state.Start = state.Start.Add(-2 * time.Minute)
+
+		errorRate = "0.2"
+		result := status(t, &state)
+		assert.False(t, result.Completed)
+		assert.Nil(t, state.HoldingSince)
+
+		errorRate = "0.005"
+		result = status(t, &state)
+		assert.False(t, result.Completed)
+		require.NotNil(t, state.HoldingSince)
+
+		// The condition started to hold 40s ago, 80s after the start of the step
+		state.HoldingSince = new(state.HoldingSince.Add(-40 * time.Second))
+		result = status(t, &state)
+		assert.True(t, result.Completed)
+		assert.Nil(t, result.Error)
+		require.NotNil(t, result.Metrics)
+		metric := (*result.Metrics)[0]
+		assert.Equal(t, recoveryTimeMetric, *metric.Name)
+		assert.Equal(t, map[string]string{"expression": "error_rate", "condition": "< 0.01"}, metric.Metric)
+		assert.InDelta(t, 80, metric.Value, 1)
+		assert.Contains(t, (*result.Messages)[0].Message, "Recovered after 1m20s")
+	})
+
+	t.Run("interruptions restart the stable period", func(t *testing.T) {
+		state := prepare(t)
+		state.HoldingSince = new(state.Start.Add(-time.Minute))
+
+		errorRate = "0.2"
+		result := status(t, &state)
+		assert.False(t, result.Completed)
+		assert.Nil(t, state.HoldingSince)
+		assert.Contains(t, state.LastViolation, "Failing series: {service=\"shop\"} = 0.2")
+	})
+
+	t.Run("fails without recovery within the maximum", func(t *testing.T) {
+		state := prepare(t)
+		state.Start = state.Start.Add(-6 * time.Minute)
+
+		errorRate = "0.2"
+		result := status(t, &state)
+		require.NotNil(t, result.Error)
+		assert.Equal(t, action_kit_api.Failed, *result.Error.Status)
+		assert.Contains(t, *result.Error.Detail, "Did not recover within 5m0s. Last evaluation: Expression 'error_rate' met < 0.01 for 0 of 1 series")
+		assert.Equal(t, *result.Error.Detail, (*result.Messages)[0].Message)
+		assert.Nil(t, result.Metrics)
+	})
+
+	t.Run("fails if the condition started to hold too late", func(t *testing.T) {
+		state := prepare(t)
+		state.Start = state.Start.Add(-6 * time.Minute)
+		state.HoldingSince = new(time.Now().Add(-10 * time.Second))
+
+		errorRate = "0.005"
+		result := status(t, &state)
+		require.NotNil(t, result.Error)
+		assert.Equal(t, "Did not recover within 5m0s, the condition only holds since 5m50s after the start.", *result.Error.Detail)
+		assert.Equal(t, action_kit_api.Error, *(*result.Messages)[0].Level)
+		require.NotNil(t, result.Metrics)
+		assert.InDelta(t, 350, (*result.Metrics)[0].Value, 1)
+	})
+
+	t.Run("fails if the condition held too late", func(t *testing.T) {
+		state := prepare(t)
+		state.Start = state.Start.Add(-7 * time.Minute)
+		state.HoldingSince = new(state.Start.Add(6 * time.Minute))
+
+		errorRate = "0.005"
+		result := status(t, &state)
+		require.NotNil(t, result.Error)
+		assert.Equal(t, "Recovered after 6m0s, expected at most 5m0s.", *result.Error.Detail)
+		assert.Equal(t, "Recovered after 6m0s, expected at most 5m0s.", (*result.Messages)[0].Message)
+		require.NotNil(t, result.Metrics)
+		metric := (*result.Metrics)[0]
+		assert.Equal(t, recoveryTimeMetric, *metric.Name)
+		assert.InDelta(t, 360, metric.Value, 1e-6)
+	})
+}
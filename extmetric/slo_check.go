@@ -0,0 +1,433 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extmetric
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+	"github.com/steadybit/action-kit/go/action_kit_sdk"
+	extension_kit "github.com/steadybit/extension-kit"
+	"github.com/steadybit/extension-kit/extbuild"
+	"github.com/steadybit/extension-kit/extutil"
+	"github.com/steadybit/extension-prometheus/v2/extcheck"
+	"github.com/steadybit/extension-prometheus/v2/extinstance"
+	"github.com/steadybit/extension-prometheus/v2/exttracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	sliTypeEvents = "events"
+	sliTypeRatio  = "ratio"
+
+	// windowVariable is the placeholder the SLI expressions use for the window they are evaluated over.
+	windowVariable = "window"
+
+	defaultSloPeriod    = 30 * 24 * time.Hour
+	defaultSloMinWindow = time.Minute
+)
+
+type SLOCheckAction struct {
+}
+
+type SLOCheckState struct {
+	TargetName string    `json:"targetName"`
+	Tenant     string    `json:"tenant,omitempty"`
+	Duration   int64     `json:"duration"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	// Ratio is the expression of the ratio of good events, with a {{ .window }} placeholder for the window.
+	Ratio     string            `json:"ratio"`
+	Variables templateVariables `json:"variables"`
+	// Objective is the SLO target as ratio, e.g., 0.999.
+	Objective float64 `json:"objective"`
+	// LongWindow, ShortWindow, MinWindow and Period are durations in milliseconds.
+	LongWindow  int64   `json:"longWindow"`
+	ShortWindow int64   `json:"shortWindow"`
+	MaxBurnRate float64 `json:"maxBurnRate"`
+	Period      int64   `json:"period"`
+	// MinWindow is the minimum window the budget consumed since the start of the check is evaluated over, so the
+	// window covers enough scrapes right after the start.
+	MinWindow int64 `json:"minWindow"`
+}
+
+func NewSLOCheckAction() action_kit_sdk.Action[SLOCheckState] {
+	return SLOCheckAction{}
+}
+
+// Make sure SLOCheckAction implements all required interfaces
+var _ action_kit_sdk.Action[SLOCheckState] = (*SLOCheckAction)(nil)
+var _ action_kit_sdk.ActionWithStatus[SLOCheckState] = (*SLOCheckAction)(nil)
+
+func (f SLOCheckAction) NewEmptyState() SLOCheckState {
+	return SLOCheckState{}
+}
+
+func (f SLOCheckAction) Describe() action_kit_api.ActionDescription {
+	return action_kit_api.ActionDescription{
+		Id:          fmt.Sprintf("%s.slo-burn-rate", extinstance.PrometheusInstanceTargetId),
+		Label:       "Prometheus SLO burn rate",
+		Description: "Check the error budget burn rate of a service level objective",
+		Version:     extbuild.GetSemverVersionStringOrUnknown(),
+		Icon:        extutil.Ptr(extinstance.PrometheusIcon),
+		Technology:  new("Prometheus"),
+
+		TargetSelection: new(action_kit_api.TargetSelection{
+			TargetType:          extinstance.PrometheusInstanceTargetId,
+			QuantityRestriction: extutil.Ptr(action_kit_api.QuantityRestrictionExactlyOne),
+			SelectionTemplates: new([]action_kit_api.TargetSelectionTemplate{
+				{
+					Label:       "instance-name",
+					Description: new("Find prometheus-instance by instance-name"),
+					Query:       "prometheus.instance.name=\"\"",
+				},
+			}),
+		}),
+		Kind:        action_kit_api.Check,
+		TimeControl: action_kit_api.TimeControlInternal,
+		Parameters: []action_kit_api.ActionParameter{
+			{
+				Label:        "Duration",
+				Name:         "duration",
+				Type:         action_kit_api.ActionParameterTypeDuration,
+				Required:     new(true),
+				DefaultValue: new("30s"),
+				Order:        new(0),
+			},
+			{
+				Label:        "SLI Type",
+				Name:         "sliType",
+				Description:  new("Whether the service level indicator is given as good and total events or as a single ratio expression."),
+				Type:         action_kit_api.ActionParameterTypeString,
+				Required:     new(true),
+				DefaultValue: new(sliTypeEvents),
+				Options: new([]action_kit_api.ParameterOption{
+					action_kit_api.ExplicitParameterOption{Label: "Good and total events", Value: sliTypeEvents},
+					action_kit_api.ExplicitParameterOption{Label: "Ratio of good events", Value: sliTypeRatio},
+				}),
+				Order: new(1),
+			},
+			{
+				Label:       "Good Events PromQL Expression",
+				Name:        "goodEvents",
+				Description: new("Rate of good events over {{ .window }}, e.g., sum(rate(http_requests_total{code!~\"5..\"}[{{ .window }}]))."),
+				Type:        action_kit_api.ActionParameterTypeString,
+				Required:    new(false),
+				Order:       new(2),
+			},
+			{
+				Label:       "Total Events PromQL Expression",
+				Name:        "totalEvents",
+				Description: new("Rate of all events over {{ .window }}, e.g., sum(rate(http_requests_total[{{ .window }}]))."),
+				Type:        action_kit_api.ActionParameterTypeString,
+				Required:    new(false),
+				Order:       new(3),
+			},
+			{
+				Label:       "Ratio PromQL Expression",
+				Name:        "ratio",
+				Description: new("Ratio of good events over {{ .window }} between 0 and 1. Only used for the SLI type 'Ratio of good events'."),
+				Type:        action_kit_api.ActionParameterTypeString,
+				Required:    new(false),
+				Order:       new(4),
+			},
+			{
+				Label:        "SLO Target",
+				Name:         "objective",
+				Description:  new("Percentage of good events the service level objective requires, e.g., 99.9."),
+				Type:         action_kit_api.ActionParameterTypeString,
+				Required:     new(true),
+				DefaultValue: new("99.9"),
+				Order:        new(5),
+			},
+			{
+				Label:        "Maximum Burn Rate",
+				Name:         "maxBurnRate",
+				Description:  new("The check fails once the burn rate exceeds this value in both windows. A burn rate of 1 consumes exactly the error budget within the SLO period."),
+				Type:         action_kit_api.ActionParameterTypeString,
+				Required:     new(true),
+				DefaultValue: new("14.4"),
+				Order:        new(6),
+			},
+			{
+				Label:        "Long Window",
+				Name:         "longWindow",
+				Description:  new("Window of the burn rate which detects a significant budget consumption."),
+				Type:         action_kit_api.ActionParameterTypeDuration,
+				Required:     new(true),
+				DefaultValue: new("1h"),
+				Order:        new(7),
+			},
+			{
+				Label:        "Short Window",
+				Name:         "shortWindow",
+				Description:  new("Window of the burn rate which makes sure the budget is still being consumed."),
+				Type:         action_kit_api.ActionParameterTypeDuration,
+				Required:     new(true),
+				DefaultValue: new("5m"),
+				Order:        new(8),
+			},
+			{
+				Label:        "SLO Period",
+				Name:         "period",
+				Description:  new("Period the error budget applies to, used to report the budget consumed during the check."),
+				Type:         action_kit_api.ActionParameterTypeDuration,
+				Required:     new(false),
+				Advanced:     new(true),
+				DefaultValue: new("720h"),
+				Order:        new(9),
+			},
+			{
+				Label:        "Minimum Window",
+				Name:         "minWindow",
+				Description:  new("Minimum window the budget consumed since the start of the check is evaluated over. Should cover a few scrape intervals."),
+				Type:         action_kit_api.ActionParameterTypeDuration,
+				Required:     new(false),
+				Advanced:     new(true),
+				DefaultValue: new("1m"),
+				Order:        new(10),
+			},
+			{
+				Label:       "Tenant",
+				Name:        "tenant",
+				Description: new("Query this tenant instead of the one configured for the instance, e.g., a Grafana Mimir organization."),
+				Type:        action_kit_api.ActionParameterTypeString,
+				Required:    new(false),
+				Advanced:    new(true),
+				Order:       new(11),
+			},
+		},
+		Prepare: action_kit_api.MutatingEndpointReference{},
+		Start:   action_kit_api.MutatingEndpointReference{},
+		Status: new(action_kit_api.MutatingEndpointReferenceWithCallInterval{
+			CallInterval: new("5s"),
+		}),
+	}
+}
+
+func (f SLOCheckAction) Prepare(ctx context.Context, state *SLOCheckState, request action_kit_api.PrepareActionRequestBody) (*action_kit_api.PrepareResult, error) {
+	if request.Target == nil {
+		return nil, new(extension_kit.ToError("No Prometheus instance selected", nil))
+	}
+
+	state.TargetName = request.Target.Name
+	state.Tenant = strings.TrimSpace(extutil.ToString(request.Config["tenant"]))
+	state.Duration = extutil.ToInt64(request.Config["duration"])
+	state.Variables = newTemplateVariables(request.ExecutionId, request.ExecutionContext, request.Properties, request.Target)
+
+	instance, _, err := getApiClient(state.TargetName, state.Tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	ratio, err := sliRatio(extutil.ToString(request.Config["sliType"]), extutil.ToString(request.Config["goodEvents"]),
+		extutil.ToString(request.Config["totalEvents"]), extutil.ToString(request.Config["ratio"]))
+	if err != nil {
+		return nil, new(extension_kit.ToError("Invalid service level indicator", err))
+	}
+	state.Ratio = ratio
+
+	objective, err := strconv.ParseFloat(strings.TrimSpace(extutil.ToString(request.Config["objective"])), 64)
+	if err != nil || objective <= 0 || objective >= 100 {
+		return nil, new(extension_kit.ToError(fmt.Sprintf("SLO target '%v' must be a percentage between 0 and 100, e.g., 99.9", request.Config["objective"]), nil))
+	}
+	state.Objective = objective / 100
+
+	state.MaxBurnRate, err = strconv.ParseFloat(strings.TrimSpace(extutil.ToString(request.Config["maxBurnRate"])), 64)
+	if err != nil || state.MaxBurnRate <= 0 {
+		return nil, new(extension_kit.ToError(fmt.Sprintf("Maximum burn rate '%v' must be a positive number", request.Config["maxBurnRate"]), nil))
+	}
+
+	state.LongWindow = extutil.ToInt64(request.Config["longWindow"])
+	state.ShortWindow = extutil.ToInt64(request.Config["shortWindow"])
+	if state.ShortWindow < 1000 || state.LongWindow < state.ShortWindow {
+		return nil, new(extension_kit.ToError("The short window must be at least 1s and the long window at least as long as the short one", nil))
+	}
+	state.Period = extutil.ToInt64(request.Config["period"])
+	if state.Period <= 0 {
+		state.Period = defaultSloPeriod.Milliseconds()
+	}
+	state.MinWindow = extutil.ToInt64(request.Config["minWindow"])
+	if state.MinWindow <= 0 {
+		state.MinWindow = defaultSloMinWindow.Milliseconds()
+	}
+
+	short, err := state.renderRatio(time.Second)
+	if err != nil {
+		return nil, new(extension_kit.ToError("Invalid placeholder in service level indicator", err))
+	}
+	long, err := state.renderRatio(2 * time.Second)
+	if err != nil {
+		return nil, new(extension_kit.ToError("Invalid placeholder in service level indicator", err))
+	}
+	if short == long {
+		return nil, new(extension_kit.ToError("The service level indicator must use the {{ .window }} placeholder for the window, e.g., rate(http_requests_total[{{ .window }}])", nil))
+	}
+	if err := validateQuery(short, instance, request.Target); err != nil {
+		return nil, new(extension_kit.ToError(fmt.Sprintf("Invalid PromQL expression '%s'", short), err))
+	}
+	return nil, nil
+}
+
+// sliRatio returns the expression of the ratio of good events. Good and total events are summed, so their labels don't
+// need to match.
+func sliRatio(sliType string, goodEvents string, totalEvents string, ratio string) (string, error) {
+	switch sliType {
+	case "", sliTypeEvents:
+		goodEvents, totalEvents = strings.TrimSpace(goodEvents), strings.TrimSpace(totalEvents)
+		if goodEvents == "" || totalEvents == "" {
+			return "", fmt.Errorf("good and total events are required")
+		}
+		return fmt.Sprintf("sum(%s) / sum(%s)", goodEvents, totalEvents), nil
+	case sliTypeRatio:
+		ratio = strings.TrimSpace(ratio)
+		if ratio == "" {
+			return "", fmt.Errorf("ratio expression is required")
+		}
+		return ratio, nil
+	default:
+		return "", fmt.Errorf("unsupported SLI type '%s', expected %s or %s", sliType, sliTypeEvents, sliTypeRatio)
+	}
+}
+
+// renderRatio renders the ratio expression for the given window, which is truncated to seconds.
+func (s *SLOCheckState) renderRatio(window time.Duration) (string, error) {
+	variables := maps.Clone(s.Variables)
+	if variables == nil {
+		variables = templateVariables{}
+	}
+	variables[windowVariable] = []string{fmt.Sprintf("%ds", int64(max(window, time.Second)/time.Second))}
+	return renderQuery(s.Ratio, variables)
+}
+
+func (f SLOCheckAction) Start(_ context.Context, state *SLOCheckState) (*action_kit_api.StartResult, error) {
+	state.Start = time.Now()
+	state.End = state.Start.Add(time.Duration(state.Duration) * time.Millisecond)
+	return nil, nil
+}
+
+func (f SLOCheckAction) Status(ctx context.Context, state *SLOCheckState) (*action_kit_api.StatusResult, error) {
+	now := time.Now()
+	completed := !now.Before(state.End)
+
+	report, err := evaluateSLO(ctx, state, now)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := fmt.Sprintf("Burn rate is %s over %s and %s over %s, expected at most %s in one of them. The check consumed %s of the error budget of %s.",
+		formatBurnRate(report.longBurnRate), time.Duration(state.LongWindow)*time.Millisecond,
+		formatBurnRate(report.shortBurnRate), time.Duration(state.ShortWindow)*time.Millisecond,
+		formatBurnRate(state.MaxBurnRate), formatBudget(report.consumedBudget), time.Duration(state.Period)*time.Millisecond)
+	if len(report.withoutEvents) > 0 {
+		windows := make([]string, len(report.withoutEvents))
+		for i, window := range report.withoutEvents {
+			windows[i] = window.String()
+		}
+		summary += fmt.Sprintf(" The service level indicator returned no events over %s.", strings.Join(windows, ", "))
+	}
+	if report.longBurnRate > state.MaxBurnRate && report.shortBurnRate > state.MaxBurnRate {
+		return extcheck.Failed("SLO burn rate check failed", summary), nil
+	}
+	if !completed {
+		return &action_kit_api.StatusResult{Completed: false}, nil
+	}
+	return &action_kit_api.StatusResult{
+		Completed: true,
+		Messages: new([]action_kit_api.Message{
+			{
+				Level:   new(action_kit_api.Info),
+				Message: summary,
+			},
+		}),
+	}, nil
+}
+
+type sloReport struct {
+	longBurnRate  float64
+	shortBurnRate float64
+	// consumedBudget is the ratio of the error budget of the SLO period consumed since the check started.
+	consumedBudget float64
+	// withoutEvents are the burn rate windows the SLI returned no data or 0/0 for. Without events, nothing is consumed.
+	withoutEvents []time.Duration
+}
+
+func evaluateSLO(ctx context.Context, state *SLOCheckState, now time.Time) (report sloReport, err error) {
+	ctx, span := exttracing.Tracer().Start(ctx, "EvaluateSLO", trace.WithAttributes(
+		exttracing.InstanceKey.String(state.TargetName),
+		exttracing.QueryKey.String(state.Ratio),
+	))
+	defer func() {
+		span.SetAttributes(
+			attribute.Float64("steadybit.slo.burn_rate.long", report.longBurnRate),
+			attribute.Float64("steadybit.slo.burn_rate.short", report.shortBurnRate),
+		)
+		exttracing.End(span, err)
+	}()
+
+	instance, client, err := getApiClient(state.TargetName, state.Tenant)
+	if err != nil {
+		return report, err
+	}
+
+	// Both an empty result, e.g., of sum(...) / sum(...) without any series, and a ratio of 0/0 mean there were no
+	// events in the window, so nothing was consumed.
+	burnRate := func(window time.Duration) (float64, error) {
+		window = max(window, time.Second).Truncate(time.Second)
+		query, err := state.renderRatio(window)
+		if err != nil {
+			return 0, new(extension_kit.ToError("Invalid placeholder in service level indicator", err))
+		}
+		samples, err := queryInstant(ctx, instance, client, query, now)
+		if err != nil {
+			return 0, new(extension_kit.ToError(fmt.Sprintf("Failed to evaluate '%s' against instance '%s'", query, state.TargetName), err))
+		}
+		switch {
+		case len(samples) > 1:
+			return 0, new(extension_kit.ToError(fmt.Sprintf("Service level indicator '%s' returned %d series, expected a single one. Aggregate it, e.g., with sum().", query, len(samples)), nil))
+		case len(samples) == 0 || math.IsNaN(float64(samples[0].Value)):
+			report.withoutEvents = append(report.withoutEvents, window)
+			return 0, nil
+		default:
+			return sloBurnRate(float64(samples[0].Value), state.Objective), nil
+		}
+	}
+
+	if report.longBurnRate, err = burnRate(time.Duration(state.LongWindow) * time.Millisecond); err != nil {
+		return report, err
+	}
+	if report.shortBurnRate, err = burnRate(time.Duration(state.ShortWindow) * time.Millisecond); err != nil {
+		return report, err
+	}
+	// Right after the start, the elapsed time covers too few scrapes for rate() to return anything, so the burn rate is
+	// evaluated over at least the minimum window.
+	elapsed := now.Sub(state.Start)
+	checkBurnRate, err := burnRate(max(elapsed, time.Duration(state.MinWindow)*time.Millisecond))
+	if err != nil {
+		return report, err
+	}
+	report.consumedBudget = checkBurnRate * max(elapsed, 0).Seconds() / (time.Duration(state.Period) * time.Millisecond).Seconds()
+	return report, nil
+}
+
+// sloBurnRate is how fast the error budget is consumed for the given ratio of good events: a burn rate of 1 consumes
+// exactly the error budget within the SLO period.
+func sloBurnRate(goodRatio float64, objective float64) float64 {
+	return max(1-goodRatio, 0) / (1 - objective)
+}
+
+func formatBurnRate(burnRate float64) string {
+	return strconv.FormatFloat(burnRate, 'f', 2, 64)
+}
+
+func formatBudget(budget float64) string {
+	return strconv.FormatFloat(budget*100, 'f', 3, 64) + "%"
+}
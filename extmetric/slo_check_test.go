@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extmetric
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+	"github.com/steadybit/action-kit/go/action_kit_sdk"
+	"github.com/steadybit/extension-prometheus/v2/extinstance"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSliRatio(t *testing.T) {
+	ratio, err := sliRatio(sliTypeEvents, " rate(good[{{ .window }}]) ", "rate(total[{{ .window }}])", "")
+	require.NoError(t, err)
+	assert.Equal(t, "sum(rate(good[{{ .window }}])) / sum(rate(total[{{ .window }}]))", ratio)
+
+	ratio, err = sliRatio(sliTypeRatio, "", "", "avg_over_time(sli[{{ .window }}])")
+	require.NoError(t, err)
+	assert.Equal(t, "avg_over_time(sli[{{ .window }}])", ratio)
+
+	_, err = sliRatio(sliTypeEvents, "rate(good[{{ .window }}])", "", "")
+	assert.ErrorContains(t, err, "good and total events are required")
+	_, err = sliRatio(sliTypeRatio, "", "", "")
+	assert.ErrorContains(t, err, "ratio expression is required")
+	_, err = sliRatio("latency", "", "", "")
+	assert.ErrorContains(t, err, "unsupported SLI type 'latency'")
+}
+
+func TestSloBurnRate(t *testing.T) {
+	assert.InDelta(t, 0, sloBurnRate(1, 0.999), 1e-9)
+	assert.InDelta(t, 1, sloBurnRate(0.999, 0.999), 1e-9)
+	assert.InDelta(t, 14.4, sloBurnRate(0.9856, 0.999), 1e-9)
+	assert.InDelta(t, 0, sloBurnRate(1.01, 0.999), 1e-9)
+}
+
+// sloServer answers ratio queries with the good ratio configured for the window of the query.
+func sloServer(t *testing.T, ratios map[string]string) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		query := r.FormValue("query")
+		for window, ratio := range ratios {
+			if strings.Contains(query, "["+window+"]") {
+				_, _ = fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1767268800,"%s"]}]}}`, ratio)
+				return
+			}
+		}
+		_, _ = fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+	}))
+	t.Cleanup(server.Close)
+	extinstance.SetInstances([]extinstance.Instance{{Name: "slo", BaseUrl: server.URL}})
+}
+
+func prepareSLOCheck(config map[string]any) (action_kit_sdk.Action[SLOCheckState], SLOCheckState, error) {
+	defaults := map[string]any{
+		"duration":    float64(60000),
+		"sliType":     sliTypeEvents,
+		"goodEvents":  `sum(rate(http_requests_total{code!~"5.."}[{{ .window }}]))`,
+		"totalEvents": `sum(rate(http_requests_total[{{ .window }}]))`,
+		"objective":   "99.9",
+		"maxBurnRate": "14.4",
+		"longWindow":  float64(3600000),
+		"shortWindow": float64(300000),
+		"minWindow":   float64(30000),
+	}
+	for key, value := range config {
+		defaults[key] = value
+	}
+	action := NewSLOCheckAction()
+	state := action.NewEmptyState()
+	_, err := action.Prepare(context.Background(), &state, prepareRequest("slo", defaults))
+	return action, state, err
+}
+
+func TestSLOCheck_Prepare(t *testing.T) {
+	sloServer(t, nil)
+
+	_, state, err := prepareSLOCheck(nil)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.999, state.Objective, 1e-9)
+	assert.Equal(t, int64(30*24*time.Hour/time.Millisecond), state.Period)
+	query, err := state.renderRatio(time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, `sum(sum(rate(http_requests_total{code!~"5.."}[3600s]))) / sum(sum(rate(http_requests_total[3600s])))`, query)
+
+	_, _, err = prepareSLOCheck(map[string]any{"sliType": sliTypeRatio, "ratio": "avg_over_time(sli[1h])"})
+	assert.ErrorContains(t, err, "must use the {{ .window }} placeholder")
+
+	_, _, err = prepareSLOCheck(map[string]any{"objective": "100"})
+	assert.ErrorContains(t, err, "must be a percentage between 0 and 100")
+
+	_, _, err = prepareSLOCheck(map[string]any{"shortWindow": float64(7200000)})
+	assert.ErrorContains(t, err, "the long window at least as long as the short one")
+
+	_, _, err = prepareSLOCheck(map[string]any{"maxBurnRate": "fast"})
+	assert.ErrorContains(t, err, "Maximum burn rate 'fast' must be a positive number")
+}
+
+func TestSLOCheck_Status(t *testing.T) {
+	tests := []struct {
+		name        string
+		ratios      map[string]string
+		wantFailure string
+		wantMessage string
+	}{
+		{
+			name:        "within budget",
+			ratios:      map[string]string{"3600s": "0.999", "300s": "0.99", "60s": "0.998"},
+			wantMessage: "Burn rate is 1.00 over 1h0m0s and 10.00 over 5m0s, expected at most 14.40 in one of them. The check consumed 0.005% of the error budget of 720h0m0s.",
+		},
+		{
+			name:        "only short window burning",
+			ratios:      map[string]string{"3600s": "0.99", "300s": "0.9", "60s": "0.9"},
+			wantMessage: "Burn rate is 10.00 over 1h0m0s and 100.00 over 5m0s",
+		},
+		{
+			name:        "both windows burning",
+			ratios:      map[string]string{"3600s": "0.98", "300s": "0.9", "60s": "0.9"},
+			wantFailure: "Burn rate is 20.00 over 1h0m0s and 100.00 over 5m0s, expected at most 14.40 in one of them. The check consumed 0.231% of the error budget of 720h0m0s.",
+		},
+		{
+			name:        "no data counts as no events",
+			ratios:      map[string]string{"3600s": "0.999"},
+			wantMessage: "Burn rate is 1.00 over 1h0m0s and 0.00 over 5m0s, expected at most 14.40 in one of them. The check consumed 0.000% of the error budget of 720h0m0s. The service level indicator returned no events over 5m0s, 1m0s.",
+		},
+		{
+			name:        "0/0 counts as no events",
+			ratios:      map[string]string{"3600s": "0.999", "300s": "NaN", "60s": "NaN"},
+			wantMessage: "The service level indicator returned no events over 5m0s, 1m0s.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sloServer(t, tt.ratios)
+			action, state, err := prepareSLOCheck(nil)
+			require.NoError(t, err)
+			state.Start = time.Now().Add(-time.Minute)
+			state.End = time.Now().Add(-time.Second)
+
+			result, err := action.(action_kit_sdk.ActionWithStatus[SLOCheckState]).Status(context.Background(), &state)
+			require.NoError(t, err)
+			assert.True(t, result.Completed)
+			if tt.wantFailure != "" {
+				require.NotNil(t, result.Error)
+				assert.Equal(t, action_kit_api.Failed, *result.Error.Status)
+				assert.Equal(t, tt.wantFailure, *result.Error.Detail)
+				return
+			}
+			assert.Nil(t, result.Error)
+			require.NotNil(t, result.Messages)
+			assert.Contains(t, (*result.Messages)[0].Message, tt.wantMessage)
+		})
+	}
+}
+
+func TestSLOCheck_StatusRightAfterStart(t *testing.T) {
+	// Windows shorter than 15s return no data, like an instance scraping every 15s
+	sloServer(t, map[string]string{"3600s": "0.999", "300s": "0.999", "30s": "0.99"})
+	action, state, err := prepareSLOCheck(nil)
+	require.NoError(t, err)
+	_, err = action.Start(context.Background(), &state)
+	require.NoError(t, err)
+	state.Start = state.Start.Add(-5 * time.Second)
+
+	result, err := action.(action_kit_sdk.ActionWithStatus[SLOCheckState]).Status(context.Background(), &state)
+	require.NoError(t, err)
+	assert.False(t, result.Completed)
+	assert.Nil(t, result.Error)
+
+	// The elapsed time is reported, even if the burn rate is evaluated over the minimum window
+	report, err := evaluateSLO(context.Background(), &state, state.Start.Add(5*time.Second))
+	require.NoError(t, err)
+	assert.InDelta(t, 10*5.0/(30*24*3600), report.consumedBudget, 1e-12)
+
+	// Without data for the minimum window, nothing was consumed yet
+	sloServer(t, map[string]string{"3600s": "0.999", "300s": "0.999"})
+	report, err = evaluateSLO(context.Background(), &state, state.Start.Add(5*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{30 * time.Second}, report.withoutEvents)
+	assert.Zero(t, report.consumedBudget)
+}
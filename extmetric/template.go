@@ -0,0 +1,185 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extmetric
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+)
+
+// templateVariables are the values placeholders like {{ .namespace }} in a query are replaced with.
+type templateVariables map[string][]string
+
+// placeholder matches {{ .name }}, where the name may contain dots, e.g., {{ .target.k8s.namespace }}.
+var placeholder = regexp.MustCompile(`\{\{\s*(.*?)\s*}}`)
+
+var placeholderName = regexp.MustCompile(`^\.([A-Za-z_][A-Za-z0-9_.\-]*)$`)
+
+// bareValue are the values which may be used outside of string literals, e.g., durations, numbers and metric names.
+var bareValue = regexp.MustCompile(`^[A-Za-z0-9_:.]+$`)
+
+// newTemplateVariables collects the variables of an execution: its properties, e.g., experiment variables or values
+// published by other steps, the execution id, the experiment key and the attributes of the target.
+func newTemplateVariables(executionId uuid.UUID, executionContext *action_kit_api.ExecutionContext, properties map[string]any, target *action_kit_api.Target) templateVariables {
+	variables := templateVariables{}
+	for key, value := range properties {
+		if values := propertyValues(value); values != nil {
+			variables[key] = values
+		}
+	}
+	variables["executionId"] = []string{executionId.String()}
+	if executionContext != nil && executionContext.ExperimentKey != nil {
+		variables["experimentKey"] = []string{*executionContext.ExperimentKey}
+	}
+	if target != nil {
+		for key, values := range target.Attributes {
+			variables["target."+key] = values
+		}
+	}
+	return variables
+}
+
+func propertyValues(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case float64, int, int64, bool:
+		return []string{fmt.Sprint(v)}
+	case []string:
+		return v
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, propertyValues(item)...)
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// renderQuery replaces the placeholders in a query. Values are escaped depending on where the placeholder is used, so
+// they can't break out of the selector or widen it:
+//   - within regular expression matchers (=~ and !~), values are matched literally and multiple values as alternatives,
+//   - within other string literals, values are escaped and must be a single one,
+//   - outside of string literals, only a single value made of letters, digits and _:. is allowed, e.g., a duration.
+func renderQuery(query string, variables templateVariables) (string, error) {
+	if !strings.Contains(query, "{{") {
+		return query, nil
+	}
+
+	var rendered strings.Builder
+	var literal queryLiteralState
+	last := 0
+	for _, match := range placeholder.FindAllStringSubmatchIndex(query, -1) {
+		text := query[last:match[0]]
+		literal.scan(text)
+		rendered.WriteString(text)
+		last = match[1]
+
+		action := query[match[2]:match[3]]
+		name := placeholderName.FindStringSubmatch(action)
+		if name == nil {
+			return "", fmt.Errorf("unsupported placeholder '{{ %s }}', expected a variable like '{{ .namespace }}'", action)
+		}
+		values, ok := variables[name[1]]
+		if !ok {
+			return "", fmt.Errorf("unknown variable '%s' in placeholder, available are %s", name[1], strings.Join(variables.names(), ", "))
+		}
+		value, err := literal.escape(values)
+		if err != nil {
+			return "", fmt.Errorf("variable '%s': %w", name[1], err)
+		}
+		rendered.WriteString(value)
+	}
+	rendered.WriteString(query[last:])
+	return rendered.String(), nil
+}
+
+func (v templateVariables) names() []string {
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// queryLiteralState tracks whether the scanned part of a query ends within a string literal.
+type queryLiteralState struct {
+	// quote is the quote character of the current string literal, 0 outside of string literals.
+	quote   rune
+	regex   bool
+	escaped bool
+	// previous are the last two characters outside of string literals, to detect regular expression matchers.
+	previous [2]rune
+}
+
+func (s *queryLiteralState) scan(text string) {
+	for _, char := range text {
+		switch {
+		case s.quote == 0 && (char == '"' || char == '\'' || char == '`'):
+			s.quote = char
+			s.regex = s.previous[1] == '~' && (s.previous[0] == '=' || s.previous[0] == '!')
+		case s.quote == 0:
+			if char != ' ' && char != '\t' && char != '\n' {
+				s.previous = [2]rune{s.previous[1], char}
+			}
+		case s.escaped:
+			s.escaped = false
+		case char == '\\' && s.quote != '`':
+			s.escaped = true
+		case char == s.quote:
+			s.quote = 0
+			s.previous = [2]rune{s.previous[1], char}
+		}
+	}
+}
+
+func (s *queryLiteralState) escape(values []string) (string, error) {
+	if s.quote == 0 {
+		if len(values) != 1 || !bareValue.MatchString(values[0]) {
+			return "", fmt.Errorf("outside of string literals only a single value made of letters, digits and _:. is allowed, got %q", values)
+		}
+		return values[0], nil
+	}
+
+	var value string
+	switch {
+	case s.regex:
+		quoted := make([]string, 0, len(values))
+		for _, v := range values {
+			quoted = append(quoted, regexp.QuoteMeta(v))
+		}
+		value = strings.Join(quoted, "|")
+		if len(quoted) != 1 {
+			value = "(?:" + value + ")"
+		}
+	case len(values) == 1:
+		value = values[0]
+	default:
+		return "", fmt.Errorf("multiple values %q are only allowed in regular expression matchers (=~ or !~)", values)
+	}
+
+	switch s.quote {
+	case '`':
+		if strings.Contains(value, "`") {
+			return "", fmt.Errorf("values in raw strings must not contain '`'")
+		}
+		return value, nil
+	case '\'':
+		escaped := strconv.Quote(value)
+		escaped = strings.ReplaceAll(escaped[1:len(escaped)-1], `\"`, `"`)
+		return strings.ReplaceAll(escaped, "'", `\'`), nil
+	default:
+		escaped := strconv.Quote(value)
+		return escaped[1 : len(escaped)-1], nil
+	}
+}
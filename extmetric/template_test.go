@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extmetric
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+	"github.com/steadybit/action-kit/go/action_kit_sdk"
+	"github.com/steadybit/extension-prometheus/v2/extinstance"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderQuery(t *testing.T) {
+	variables := templateVariables{
+		"namespace":  {"shop"},
+		"window":     {"5m"},
+		"injection":  {`shop"} or up{job="`},
+		"regex":      {"shop.*"},
+		"namespaces": {"shop", "checkout-v2"},
+		"quote":      {`it's "quoted"`},
+		"backtick":   {"a`b"},
+	}
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "without placeholders",
+			query: `sum(rate(http_requests_total{code=~"5.."}[1m]))`,
+			want:  `sum(rate(http_requests_total{code=~"5.."}[1m]))`,
+		},
+		{
+			name:  "label value",
+			query: `sum(rate(http_requests_total{namespace="{{ .namespace }}",code=~"5.."}[{{.window}}]))`,
+			want:  `sum(rate(http_requests_total{namespace="shop",code=~"5.."}[5m]))`,
+		},
+		{
+			name:  "injection is escaped",
+			query: `up{namespace="{{ .injection }}"}`,
+			want:  `up{namespace="shop\"} or up{job=\""}`,
+		},
+		{
+			name:  "regular expressions are matched literally",
+			query: `up{namespace=~"{{ .regex }}"}`,
+			want:  `up{namespace=~"shop\\.\\*"}`,
+		},
+		{
+			name:  "multiple values as alternatives",
+			query: `up{namespace !~ "prefix-{{ .namespaces }}"}`,
+			want:  `up{namespace !~ "prefix-(?:shop|checkout-v2)"}`,
+		},
+		{
+			name:  "single quotes",
+			query: `up{namespace='{{ .quote }}'}`,
+			want:  `up{namespace='it\'s "quoted"'}`,
+		},
+		{
+			name:  "escaped quote before placeholder",
+			query: `up{a="\"",namespace="{{ .namespace }}"}`,
+			want:  `up{a="\"",namespace="shop"}`,
+		},
+		{
+			name:    "multiple values in equality matcher",
+			query:   `up{namespace="{{ .namespaces }}"}`,
+			wantErr: "multiple values",
+		},
+		{
+			name:    "unsafe value outside of string literal",
+			query:   `rate(up[{{ .injection }}])`,
+			wantErr: "outside of string literals",
+		},
+		{
+			name:    "backtick in raw string",
+			query:   "up{namespace=`{{ .backtick }}`}",
+			wantErr: "must not contain '`'",
+		},
+		{
+			name:    "unknown variable",
+			query:   `up{namespace="{{ .ns }}"}`,
+			wantErr: "unknown variable 'ns' in placeholder, available are backtick, injection",
+		},
+		{
+			name:    "functions are not supported",
+			query:   `up{namespace="{{ printf "%s" .namespace }}"}`,
+			wantErr: "unsupported placeholder",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderQuery(tt.query, variables)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewTemplateVariables(t *testing.T) {
+	executionId := uuid.New()
+	variables := newTemplateVariables(executionId,
+		&action_kit_api.ExecutionContext{ExperimentKey: new("ADM-1")},
+		map[string]any{"namespace": "shop", "replicas": float64(3), "pods": []any{"a", "b"}, "nested": map[string]any{"x": "y"}},
+		&action_kit_api.Target{Name: "prom", Attributes: map[string][]string{"prometheus.instance.name": {"prom"}}},
+	)
+	assert.Equal(t, templateVariables{
+		"namespace":                       {"shop"},
+		"replicas":                        {"3"},
+		"pods":                            {"a", "b"},
+		"executionId":                     {executionId.String()},
+		"experimentKey":                   {"ADM-1"},
+		"target.prometheus.instance.name": {"prom"},
+	}, variables)
+}
+
+func TestQueryMetrics_RendersVariablesOfRequest(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.FormValue("query"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+	}))
+	t.Cleanup(server.Close)
+	extinstance.SetInstances([]extinstance.Instance{{Name: "templated", BaseUrl: server.URL}})
+	action := NewMetricCheckAction().(action_kit_sdk.ActionWithMetricQuery[MetricCheckState])
+	target := &action_kit_api.Target{Name: "templated", Attributes: map[string][]string{"prometheus.instance.name": {"templated"}}}
+
+	executionId := uuid.New()
+	_, err := action.QueryMetrics(context.Background(), action_kit_api.QueryMetricsRequestBody{
+		ExecutionId: executionId,
+		Target:      target,
+		Timestamp:   time.Now(),
+		Config:      map[string]any{"query": `up{instance="{{ .target.prometheus.instance.name }}",execution="{{ .executionId }}"}`},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{`up{instance="templated",execution="` + executionId.String() + `"}`}, queries)
+
+	// The properties of the execution aren't part of metrics queries
+	_, err = action.QueryMetrics(context.Background(), action_kit_api.QueryMetricsRequestBody{
+		ExecutionId: executionId,
+		Target:      target,
+		Timestamp:   time.Now(),
+		Config:      map[string]any{"query": `up{namespace="{{ .namespace }}"}`},
+	})
+	assert.ErrorContains(t, err, "unknown variable 'namespace'")
+}
@@ -0,0 +1,267 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extscrape
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+	"github.com/steadybit/action-kit/go/action_kit_sdk"
+	extension_kit "github.com/steadybit/extension-kit"
+	"github.com/steadybit/extension-kit/extbuild"
+	"github.com/steadybit/extension-kit/extutil"
+	"github.com/steadybit/extension-prometheus/v2/extcheck"
+	"github.com/steadybit/extension-prometheus/v2/extinstance"
+)
+
+// maxReportedTargets limits the number of down targets listed in a check message.
+const maxReportedTargets = 10
+
+type ScrapeHealthCheckAction struct {
+}
+
+type ScrapeHealthCheckState struct {
+	TargetName string            `json:"targetName"`
+	Job        string            `json:"job"`
+	Labels     map[string]string `json:"labels"`
+	MaxDown    int               `json:"maxDown"`
+	CheckMode  string            `json:"checkMode"`
+	// UnknownIsDown counts targets which weren't scraped yet as down.
+	UnknownIsDown bool      `json:"unknownIsDown"`
+	Duration      int64     `json:"duration"`
+	End           time.Time `json:"end"`
+	// HealthyOnce is set once an evaluation found at most MaxDown targets down. Only relevant for extcheck.ModeAtLeastOnce.
+	HealthyOnce bool `json:"healthyOnce"`
+	// LastViolation describes the most recent evaluation with too many targets down.
+	LastViolation string `json:"lastViolation,omitempty"`
+}
+
+func NewScrapeHealthCheckAction() action_kit_sdk.Action[ScrapeHealthCheckState] {
+	return ScrapeHealthCheckAction{}
+}
+
+// Make sure ScrapeHealthCheckAction implements all required interfaces
+var _ action_kit_sdk.Action[ScrapeHealthCheckState] = (*ScrapeHealthCheckAction)(nil)
+var _ action_kit_sdk.ActionWithStatus[ScrapeHealthCheckState] = (*ScrapeHealthCheckAction)(nil)
+
+func (f ScrapeHealthCheckAction) NewEmptyState() ScrapeHealthCheckState {
+	return ScrapeHealthCheckState{}
+}
+
+func (f ScrapeHealthCheckAction) Describe() action_kit_api.ActionDescription {
+	return action_kit_api.ActionDescription{
+		Id:          fmt.Sprintf("%s.scrape-health", extinstance.PrometheusInstanceTargetId),
+		Label:       "Prometheus scrape health",
+		Description: "Check the scrape health of Prometheus targets",
+		Version:     extbuild.GetSemverVersionStringOrUnknown(),
+		Icon:        extutil.Ptr(extinstance.PrometheusIcon),
+		Technology:  new("Prometheus"),
+
+		TargetSelection: new(action_kit_api.TargetSelection{
+			TargetType:          extinstance.PrometheusInstanceTargetId,
+			QuantityRestriction: extutil.Ptr(action_kit_api.QuantityRestrictionExactlyOne),
+			SelectionTemplates: new([]action_kit_api.TargetSelectionTemplate{
+				{
+					Label:       "instance-name",
+					Description: new("Find prometheus-instance by instance-name"),
+					Query:       "prometheus.instance.name=\"\"",
+				},
+			}),
+		}),
+		Kind:        action_kit_api.Check,
+		TimeControl: action_kit_api.TimeControlInternal,
+		Parameters: []action_kit_api.ActionParameter{
+			{
+				Label:        "Duration",
+				Name:         "duration",
+				Type:         action_kit_api.ActionParameterTypeDuration,
+				Required:     new(true),
+				DefaultValue: new("30s"),
+				Order:        new(0),
+			},
+			{
+				Label:       "Job",
+				Name:        "job",
+				Description: new("Only check scrape targets of this job."),
+				Type:        action_kit_api.ActionParameterTypeString,
+				Required:    new(true),
+				Options: new([]action_kit_api.ParameterOption{
+					action_kit_api.ParameterOptionsFromTargetAttribute{Attribute: "prometheus.scrape.job"},
+				}),
+				Order: new(1),
+			},
+			{
+				Label:       "Target Labels",
+				Name:        "targetLabels",
+				Description: new("Only check scrape targets having all of these labels, e.g. namespace=shop."),
+				Type:        action_kit_api.ActionParameterTypeKeyValue,
+				Required:    new(false),
+				Order:       new(2),
+			},
+			{
+				Label:        "Maximum Down Targets",
+				Name:         "maxDown",
+				Description:  new("How many of the matching scrape targets may be down?"),
+				Type:         action_kit_api.ActionParameterTypeInteger,
+				Required:     new(true),
+				DefaultValue: new("0"),
+				MinValue:     new(0),
+				Order:        new(3),
+			},
+			extcheck.ModeParameter("When must the scrape targets be healthy?", 4),
+			{
+				Label:        "Count Unknown as Down",
+				Name:         "unknownIsDown",
+				Description:  new("Count targets which weren't scraped yet, e.g., right after they were discovered, as down."),
+				Type:         action_kit_api.ActionParameterTypeBoolean,
+				Required:     new(false),
+				Advanced:     new(true),
+				DefaultValue: new("false"),
+				Order:        new(5),
+			},
+		},
+		Prepare: action_kit_api.MutatingEndpointReference{},
+		Start:   action_kit_api.MutatingEndpointReference{},
+		Status: new(action_kit_api.MutatingEndpointReferenceWithCallInterval{
+			CallInterval: new("2s"),
+		}),
+	}
+}
+
+func (f ScrapeHealthCheckAction) Prepare(_ context.Context, state *ScrapeHealthCheckState, request action_kit_api.PrepareActionRequestBody) (*action_kit_api.PrepareResult, error) {
+	if request.Target == nil {
+		return nil, new(extension_kit.ToError("No Prometheus instance selected", nil))
+	}
+	if _, err := extinstance.FindInstanceByName(request.Target.Name); err != nil {
+		return nil, new(extension_kit.ToError(fmt.Sprintf("Failed to find Prometheus instance named '%s'", request.Target.Name), err))
+	}
+
+	state.TargetName = request.Target.Name
+	state.Duration = extutil.ToInt64(request.Config["duration"])
+	state.Job = strings.TrimSpace(extutil.ToString(request.Config["job"]))
+	if state.Job == "" {
+		return nil, new(extension_kit.ToError("No scrape job defined", nil))
+	}
+
+	if request.Config["targetLabels"] != nil {
+		labels, err := extutil.ToKeyValue(request.Config, "targetLabels")
+		if err != nil {
+			return nil, new(extension_kit.ToError("Invalid target labels", err))
+		}
+		state.Labels = labels
+	}
+
+	state.MaxDown = extutil.ToInt(request.Config["maxDown"])
+	if state.MaxDown < 0 {
+		return nil, new(extension_kit.ToError("Maximum down targets must be 0 or a positive integer", nil))
+	}
+
+	checkMode, err := extcheck.ParseMode(extutil.ToString(request.Config["checkMode"]))
+	if err != nil {
+		return nil, new(extension_kit.ToError("Invalid check mode", err))
+	}
+	state.CheckMode = checkMode
+	state.UnknownIsDown = extutil.ToBool(request.Config["unknownIsDown"])
+	return nil, nil
+}
+
+func (f ScrapeHealthCheckAction) Start(_ context.Context, state *ScrapeHealthCheckState) (*action_kit_api.StartResult, error) {
+	state.End = time.Now().Add(time.Duration(state.Duration) * time.Millisecond)
+	return nil, nil
+}
+
+func (f ScrapeHealthCheckAction) Status(ctx context.Context, state *ScrapeHealthCheckState) (*action_kit_api.StatusResult, error) {
+	completed := !time.Now().Before(state.End)
+
+	// Only the final evaluation decides the verdict, so there is no need to query before.
+	if extcheck.SkipEvaluation(state.CheckMode, completed) {
+		return &action_kit_api.StatusResult{Completed: false}, nil
+	}
+
+	violation, err := evaluateScrapeHealth(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	if violation == "" {
+		state.HealthyOnce = true
+	} else {
+		state.LastViolation = violation
+	}
+
+	return extcheck.Verdict("Scrape health check failed", state.CheckMode, completed, violation, state.HealthyOnce,
+		fmt.Sprintf("Scrape targets were not healthy once during the check. Last evaluation: %s", state.LastViolation)), nil
+}
+
+// evaluateScrapeHealth returns a description of the down scrape targets if there are more than allowed, or an empty
+// string otherwise.
+func evaluateScrapeHealth(ctx context.Context, state *ScrapeHealthCheckState) (string, error) {
+	instance, err := extinstance.FindInstanceByName(state.TargetName)
+	if err != nil {
+		return "", new(extension_kit.ToError(fmt.Sprintf("Failed to find Prometheus instance named '%s'", state.TargetName), err))
+	}
+
+	client, err := instance.GetApiClient()
+	if err != nil {
+		return "", new(extension_kit.ToError("Failed to initialize Prometheus API client", err))
+	}
+
+	targets, err := fetchActiveTargets(ctx, instance, client)
+	if err != nil {
+		return "", new(extension_kit.ToError(fmt.Sprintf("Failed to fetch scrape targets from instance '%s'", state.TargetName), err))
+	}
+
+	matching := 0
+	var down []v1.ActiveTarget
+	for _, target := range targets {
+		if string(target.Labels["job"]) != state.Job || !extcheck.HasLabels(target.Labels, state.Labels) {
+			continue
+		}
+		matching++
+		if target.Health == v1.HealthBad || (target.Health == v1.HealthUnknown && state.UnknownIsDown) {
+			down = append(down, target)
+		}
+	}
+
+	if matching == 0 {
+		return fmt.Sprintf("No scrape targets of job '%s' match the selection.", state.Job), nil
+	}
+	if len(down) <= state.MaxDown {
+		return "", nil
+	}
+	return fmt.Sprintf("%d of %d scrape targets of job '%s' are down, expected at most %d. Down targets: %s",
+		len(down), matching, state.Job, state.MaxDown, describeTargets(down)), nil
+}
+
+func fetchActiveTargets(ctx context.Context, instance *extinstance.Instance, client v1.API) ([]v1.ActiveTarget, error) {
+	var targets []v1.ActiveTarget
+	err := instance.Retry(ctx, func(ctx context.Context) error {
+		result, err := client.Targets(ctx)
+		if err != nil {
+			return err
+		}
+		targets = result.Active
+		return nil
+	})
+	return targets, err
+}
+
+func describeTargets(targets []v1.ActiveTarget) string {
+	parts := make([]string, 0, min(len(targets), maxReportedTargets))
+	for i, target := range targets {
+		if i == maxReportedTargets {
+			parts = append(parts, fmt.Sprintf("and %d more", len(targets)-maxReportedTargets))
+			break
+		}
+		if target.LastError != "" {
+			parts = append(parts, fmt.Sprintf("%s (%s: %s)", target.Labels["instance"], target.Health, target.LastError))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s (%s)", target.Labels["instance"], target.Health))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,175 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package extscrape
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+	"github.com/steadybit/action-kit/go/action_kit_sdk"
+	"github.com/steadybit/extension-prometheus/v2/extcheck"
+	"github.com/steadybit/extension-prometheus/v2/extinstance"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTargets = `[
+  {"discoveredLabels":{},"labels":{"instance":"10.0.0.1:8080","job":"checkout","namespace":"shop"},"scrapePool":"pods","scrapeUrl":"http://10.0.0.1:8080/metrics","lastError":"","health":"up"},
+  {"discoveredLabels":{},"labels":{"instance":"10.0.0.2:8080","job":"checkout","namespace":"shop"},"scrapePool":"pods","scrapeUrl":"http://10.0.0.2:8080/metrics","lastError":"Get \"http://10.0.0.2:8080/metrics\": dial tcp 10.0.0.2:8080: connect: connection refused","health":"down"},
+  {"discoveredLabels":{},"labels":{"instance":"10.0.0.3:8080","job":"checkout","namespace":"staging"},"scrapePool":"pods","scrapeUrl":"http://10.0.0.3:8080/metrics","lastError":"","health":"up"},
+  {"discoveredLabels":{},"labels":{"instance":"10.0.0.4:9100","job":"node"},"scrapePool":"nodes","scrapeUrl":"http://10.0.0.4:9100/metrics","lastError":"","health":"down"}
+]`
+
+func TestPrepare(t *testing.T) {
+	extinstance.SetInstances([]extinstance.Instance{{Name: "test-prom", BaseUrl: "http://localhost:9090"}})
+	action := NewScrapeHealthCheckAction()
+
+	state := action.NewEmptyState()
+	_, err := action.Prepare(context.Background(), &state, prepareRequest(map[string]any{
+		"duration":      float64(30000),
+		"job":           "checkout",
+		"targetLabels":  []any{map[string]any{"key": "namespace", "value": "shop"}},
+		"maxDown":       float64(1),
+		"checkMode":     extcheck.ModeAtEnd,
+		"unknownIsDown": true,
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "checkout", state.Job)
+	assert.Equal(t, map[string]string{"namespace": "shop"}, state.Labels)
+	assert.Equal(t, 1, state.MaxDown)
+	assert.Equal(t, extcheck.ModeAtEnd, state.CheckMode)
+	assert.True(t, state.UnknownIsDown)
+
+	state = action.NewEmptyState()
+	_, err = action.Prepare(context.Background(), &state, prepareRequest(map[string]any{
+		"job":       "checkout",
+		"checkMode": "never",
+	}))
+	assert.ErrorContains(t, err, "unsupported check mode 'never'")
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		job        string
+		labels     map[string]string
+		maxDown    int
+		wantDetail string
+	}{
+		{name: "one down allowed", job: "checkout", maxDown: 1},
+		{
+			name:       "too many down",
+			job:        "checkout",
+			maxDown:    0,
+			wantDetail: "1 of 3 scrape targets of job 'checkout' are down, expected at most 0. Down targets: 10.0.0.2:8080 (down: Get \"http://10.0.0.2:8080/metrics\": dial tcp 10.0.0.2:8080: connect: connection refused)",
+		},
+		{name: "filtered by labels", job: "checkout", labels: map[string]string{"namespace": "staging"}, maxDown: 0},
+		{name: "no matching targets", job: "payment", wantDetail: "No scrape targets of job 'payment' match the selection."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := setupTargetsInstance(t, testTargets)
+			extinstance.SetInstances([]extinstance.Instance{{Name: "test-prom", BaseUrl: url}})
+			action := NewScrapeHealthCheckAction().(action_kit_sdk.ActionWithStatus[ScrapeHealthCheckState])
+
+			state := ScrapeHealthCheckState{
+				TargetName: "test-prom",
+				Job:        tt.job,
+				Labels:     tt.labels,
+				MaxDown:    tt.maxDown,
+				CheckMode:  extcheck.ModeAllTheTime,
+				End:        time.Now().Add(time.Minute),
+			}
+			result, err := action.Status(context.Background(), &state)
+			require.NoError(t, err)
+
+			if tt.wantDetail != "" {
+				require.NotNil(t, result.Error)
+				assert.True(t, result.Completed)
+				assert.Equal(t, action_kit_api.Failed, *result.Error.Status)
+				assert.Equal(t, tt.wantDetail, *result.Error.Detail)
+				return
+			}
+			assert.Nil(t, result.Error)
+			assert.False(t, result.Completed)
+		})
+	}
+}
+
+func TestStatus_UnknownHealth(t *testing.T) {
+	url := setupTargetsInstance(t, `[
+  {"discoveredLabels":{},"labels":{"instance":"10.0.0.1:8080","job":"checkout"},"scrapePool":"pods","scrapeUrl":"http://10.0.0.1:8080/metrics","lastError":"","health":"up"},
+  {"discoveredLabels":{},"labels":{"instance":"10.0.0.5:8080","job":"checkout"},"scrapePool":"pods","scrapeUrl":"http://10.0.0.5:8080/metrics","lastError":"","health":"unknown"}
+]`)
+	extinstance.SetInstances([]extinstance.Instance{{Name: "test-prom", BaseUrl: url}})
+	action := NewScrapeHealthCheckAction().(action_kit_sdk.ActionWithStatus[ScrapeHealthCheckState])
+
+	state := ScrapeHealthCheckState{TargetName: "test-prom", Job: "checkout", CheckMode: extcheck.ModeAllTheTime, End: time.Now().Add(time.Minute)}
+	result, err := action.Status(context.Background(), &state)
+	require.NoError(t, err)
+	assert.Nil(t, result.Error)
+
+	state.UnknownIsDown = true
+	result, err = action.Status(context.Background(), &state)
+	require.NoError(t, err)
+	require.NotNil(t, result.Error)
+	assert.Equal(t, "1 of 2 scrape targets of job 'checkout' are down, expected at most 0. Down targets: 10.0.0.5:8080 (unknown)", *result.Error.Detail)
+}
+
+func TestStatus_AtEndOnlyEvaluatesOnce(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"status":"success","data":{"activeTargets":%s,"droppedTargets":[]}}`, testTargets)
+	}))
+	t.Cleanup(server.Close)
+	extinstance.SetInstances([]extinstance.Instance{{Name: "test-prom", BaseUrl: server.URL}})
+	action := NewScrapeHealthCheckAction().(action_kit_sdk.ActionWithStatus[ScrapeHealthCheckState])
+
+	state := ScrapeHealthCheckState{TargetName: "test-prom", Job: "node", CheckMode: extcheck.ModeAtEnd, End: time.Now().Add(time.Minute)}
+	result, err := action.Status(context.Background(), &state)
+	require.NoError(t, err)
+	assert.False(t, result.Completed)
+	assert.Equal(t, 0, requests)
+
+	state.End = time.Now().Add(-time.Second)
+	result, err = action.Status(context.Background(), &state)
+	require.NoError(t, err)
+	assert.True(t, result.Completed)
+	require.NotNil(t, result.Error)
+	assert.Equal(t, 1, requests)
+}
+
+func prepareRequest(config map[string]any) action_kit_api.PrepareActionRequestBody {
+	return action_kit_api.PrepareActionRequestBody{
+		Target: new(action_kit_api.Target{
+			Name: "test-prom",
+		}),
+		Config: config,
+	}
+}
+
+func setupTargetsInstance(t *testing.T, targets string) (url string) {
+	t.Helper()
+
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/v1/targets", r.URL.Path)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			if _, err := fmt.Fprintf(w, `{"status":"success","data":{"activeTargets":%s,"droppedTargets":[]}}`, targets); err != nil {
+				http.Error(w, "Failed to write response", http.StatusInternalServerError)
+			}
+		}),
+	)
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package exttracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/steadybit/extension-kit/extbuild"
+	"github.com/steadybit/extension-prometheus/v2/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	serviceName = "steadybit-extension-prometheus"
+	// ExecutionIdKey is the ID of the experiment execution a query is made for.
+	ExecutionIdKey = attribute.Key("steadybit.execution.id")
+	// InstanceKey is the name of the Prometheus instance queried.
+	InstanceKey = attribute.Key("prometheus.instance.name")
+	// QueryKey is the PromQL query.
+	QueryKey = attribute.Key("db.query.text")
+)
+
+// Init registers an OTLP exporter as global tracer provider if tracing is enabled. The exporter is configured by the
+// standard OTEL_EXPORTER_OTLP_* environment variables, the sampler by OTEL_TRACES_SAMPLER. The returned function
+// flushes pending spans on shutdown.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	if !config.Config.TracingEnabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(extbuild.GetSemverVersionStringOrUnknown()),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTel resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	// The trace context is propagated to Prometheus, e.g., to the Thanos or Mimir query frontend.
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	log.Info().Msg("OpenTelemetry tracing enabled.")
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer of the extension. Spans are dropped unless tracing is enabled.
+func Tracer() trace.Tracer {
+	return otel.Tracer("github.com/steadybit/extension-prometheus/v2")
+}
+
+// End records the error, if any, and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
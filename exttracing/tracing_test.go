@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2026 Steadybit GmbH
+
+package exttracing_test
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/steadybit/extension-prometheus/v2/config"
+	"github.com/steadybit/extension-prometheus/v2/extinstance"
+	"github.com/steadybit/extension-prometheus/v2/exttracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace/noop"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// newCollector returns a stand-in of an OTLP collector which records the received spans.
+func newCollector(t *testing.T) (*httptest.Server, func() []*tracepb.Span) {
+	var mu sync.Mutex
+	var spans []*tracepb.Span
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var request collectortrace.ExportTraceServiceRequest
+		require.NoError(t, proto.Unmarshal(body, &request))
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, resourceSpans := range request.ResourceSpans {
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				spans = append(spans, scopeSpans.Spans...)
+			}
+		}
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		out, _ := proto.Marshal(&collectortrace.ExportTraceServiceResponse{})
+		_, _ = w.Write(out)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []*tracepb.Span {
+		mu.Lock()
+		defer mu.Unlock()
+		return spans
+	}
+}
+
+func attribute(span *tracepb.Span, key string) string {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value.GetStringValue()
+		}
+	}
+	return ""
+}
+
+func TestInit_Disabled(t *testing.T) {
+	config.Config.TracingEnabled = false
+	shutdown, err := exttracing.Init(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+	_, span := exttracing.Tracer().Start(context.Background(), "test")
+	assert.False(t, span.IsRecording())
+}
+
+func TestInit_ExportsQuerySpans(t *testing.T) {
+	collector, collected := newCollector(t)
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", collector.URL)
+	config.Config.TracingEnabled = true
+	t.Cleanup(func() {
+		config.Config.TracingEnabled = false
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+
+	var traceparents []string
+	requests := 0
+	prometheus := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		requests++
+		if requests == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status": "success", "data": {"resultType": "vector", "result": []}}`))
+	}))
+	t.Cleanup(prometheus.Close)
+
+	shutdown, err := exttracing.Init(context.Background())
+	require.NoError(t, err)
+
+	instance := &extinstance.Instance{Name: "traced", BaseUrl: prometheus.URL, QueryRetries: new(1)}
+	client, err := instance.GetApiClient()
+	require.NoError(t, err)
+
+	ctx, span := exttracing.Tracer().Start(context.Background(), "QueryMetrics")
+	err = instance.Retry(ctx, func(ctx context.Context) error {
+		_, _, err := client.Query(ctx, "up", time.Now())
+		return err
+	})
+	exttracing.End(span, err)
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+
+	traceId := span.SpanContext().TraceID().String()
+	require.Len(t, traceparents, 2)
+	for _, traceparent := range traceparents {
+		assert.Contains(t, traceparent, traceId, "trace context is propagated to Prometheus")
+	}
+
+	names := map[string]int{}
+	for _, s := range collected() {
+		assert.Equal(t, traceId, hex.EncodeToString(s.TraceId))
+		names[s.Name]++
+		if s.Name == "prometheus.attempt" {
+			assert.Equal(t, "traced", attribute(s, string(exttracing.InstanceKey)))
+		}
+	}
+	assert.Equal(t, map[string]int{"QueryMetrics": 1, "prometheus.attempt": 2, "POST /api/v1/query": 2}, names)
+}
@@ -4,8 +4,16 @@
 package main
 
 import (
+	"context"
+	"net/http"
+	"os"
+	"syscall"
+	"time"
+
 	_ "github.com/KimMachineGun/automemlimit" // By default, it sets `GOMEMLIMIT` to 90% of cgroup's memory limit.
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 	"github.com/steadybit/action-kit/go/action_kit_api/v2"
 	"github.com/steadybit/action-kit/go/action_kit_sdk"
 	"github.com/steadybit/discovery-kit/go/discovery_kit_api"
@@ -17,8 +25,11 @@ import (
 	"github.com/steadybit/extension-kit/extruntime"
 	"github.com/steadybit/extension-kit/extsignals"
 	"github.com/steadybit/extension-prometheus/v2/config"
+	"github.com/steadybit/extension-prometheus/v2/extalert"
 	"github.com/steadybit/extension-prometheus/v2/extinstance"
 	"github.com/steadybit/extension-prometheus/v2/extmetric"
+	"github.com/steadybit/extension-prometheus/v2/extscrape"
+	"github.com/steadybit/extension-prometheus/v2/exttracing"
 )
 
 func main() {
@@ -34,10 +45,43 @@ func main() {
 	config.ParseConfiguration()
 	config.ValidateConfiguration()
 
+	shutdownTracing, err := exttracing.Init(context.Background())
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize tracing.")
+	}
+	extsignals.AddSignalHandler(extsignals.SignalHandler{
+		Handler: func(signal os.Signal) {
+			if signal != syscall.SIGINT && signal != syscall.SIGTERM {
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				log.Warn().Err(err).Msg("Failed to flush pending spans.")
+			}
+		},
+		Order: extsignals.OrderStopCustom,
+		Name:  "Tracing",
+	})
+
+	if config.Config.InstancesConfigFile != "" {
+		err := extinstance.WatchInstancesConfigFile(context.Background(), config.Config.InstancesConfigFile, config.Config.InstancesConfigReloadInterval)
+		if err != nil {
+			log.Fatal().Err(err).Msgf("Failed to load instances config file '%s'.", config.Config.InstancesConfigFile)
+		}
+	}
+
 	discovery_kit_sdk.Register(extinstance.NewInstanceDiscovery())
+	discovery_kit_sdk.Register(extinstance.NewRuleDiscovery())
+	discovery_kit_sdk.Register(extinstance.NewScrapeTargetDiscovery())
 	action_kit_sdk.RegisterAction(extmetric.NewMetricCheckAction())
+	action_kit_sdk.RegisterAction(extmetric.NewSLOCheckAction())
+	action_kit_sdk.RegisterAction(extmetric.NewRecoveryTimeAction())
+	action_kit_sdk.RegisterAction(extalert.NewAlertCheckAction())
+	action_kit_sdk.RegisterAction(extscrape.NewScrapeHealthCheckAction())
 
 	exthttp.RegisterRevisionedHandler("/", getExtensionList)
+	http.Handle("/metrics", promhttp.Handler())
 
 	extsignals.ActivateSignalHandlers()
 